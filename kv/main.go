@@ -30,6 +30,7 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -43,8 +44,7 @@ import (
 
 	"github.com/codahale/hdrhistogram"
 	"github.com/gocql/gocql"
-	// Import postgres driver.
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 var readPercent = flag.Int("read-percent", 0, "Percent (0-100) of operations that are reads of existing keys")
@@ -58,6 +58,29 @@ var batch = flag.Int("batch", 1, "Number of blocks to insert in a single SQL sta
 
 var splits = flag.Int("splits", 0, "Number of splits to perform before starting normal operations")
 
+// Schema flags, used with SQL databases. Use -drop when changing them.
+var secondaryIndexes = flag.Int("secondary-indexes", 0, "Number of extra columns, each with a secondary index, "+
+	"written with every block")
+var compositePK = flag.Bool("composite-pk", false, "Use a two column primary key (p, k), where p is the key modulo 16")
+var storingIndexes = flag.Bool("storing-indexes", false, "Store the blocks in the secondary indexes (cockroach only)")
+var indexReads = flag.Bool("index-reads", false, "Read blocks through the first secondary index instead of "+
+	"the primary key")
+
+var writeMode = flag.String("write-mode", "upsert", "Statement used to write blocks with SQL databases: upsert, "+
+	"insert (duplicate keys are counted rather than failing), insert-on-conflict-do-nothing or update. "+
+	"update rewrites the keys written by an earlier run, and requires the -seed and the final "+
+	"write sequence printed on exit by that run (as -write-seq). update cannot be used with -cycle-length.")
+var dialect = flag.String("dialect", "cockroach", "SQL database being tested: cockroach or postgres. "+
+	"With postgres, the database in the URL must exist and the table is created in its test schema.")
+
+// Connection flags, used with cockroach.
+var connPerWorker = flag.Bool("conn-per-worker", false, "Give each worker its own connection instead of "+
+	"sharing a pool of connections to each URL")
+var urlBalance = flag.String("url-balance", "round-robin", "How workers are spread across the URLs: round-robin or random")
+var connLifetime = flag.Duration("conn-lifetime", 0, "Maximum lifetime of a connection. If 0, connections are reused forever.")
+var reconnectInterval = flag.Duration("reconnect-interval", 0, "Interval at which each worker replaces its "+
+	"connection with one to a newly chosen URL. Requires -conn-per-worker. If 0, never reconnect.")
+
 var tolerateErrors = flag.Bool("tolerate-errors", false, "Keep running on error")
 
 var maxRate = flag.Float64("max-rate", 0, "Maximum frequency of operations (reads/writes). If 0, no limit.")
@@ -69,10 +92,17 @@ var outputInterval = flag.Duration("output-interval", 1*time.Second, "Interval o
 var minBlockSizeBytes = flag.Int("min-block-bytes", 1, "Minimum amount of raw data written with each insertion")
 var maxBlockSizeBytes = flag.Int("max-block-bytes", 2, "Maximum amount of raw data written with each insertion")
 
-var maxOps = flag.Uint64("max-ops", 0, "Maximum number of blocks to read/write")
+var valueType = flag.String("value-type", "random", "Contents of the inserted blocks: random, text, json or zeros")
+var valueCompressibility = flag.Float64("value-compressibility", 1, "Ratio by which random blocks compress. "+
+	"Only the first 1/ratio of each block is random, the rest is zero.")
+
+var maxOps = flag.Uint64("max-ops", 0, "Maximum number of blocks to read/write, including the blocks "+
+	"not written because of duplicate keys or missing update keys")
 var duration = flag.Duration("duration", 0, "The duration to run. If 0, run forever.")
-var writeSeq = flag.Int64("write-seq", 0, "Initial write sequence value.")
-var seqSeed = flag.Int64("seed", time.Now().UnixNano(), "Key hash seed.")
+var writeSeq = flag.Int64("write-seq", 0, "Initial write sequence value. The final value is printed on "+
+	"exit, and is the write-seq to pass to a later -write-mode=update run.")
+var seed = flag.Int64("seed", time.Now().UnixNano(), "Key hash seed, from which the random number generators "+
+	"of the workers are also seeded. Runs with the same seed and concurrency are reproducible.")
 var sequential = flag.Bool("sequential", false, "Pick keys sequentially instead of randomly.")
 var drop = flag.Bool("drop", false, "Clear the existing data before starting.")
 var benchmarkName = flag.String("benchmark-name", "BenchmarkBlocks", "Test name to report "+
@@ -89,6 +119,32 @@ var cassandraReplication = flag.Int("cassandra-replication", 1, "Replication fac
 // numOps keeps a global count of successful operations.
 var numOps uint64
 
+// numDuplicates keeps a global count of the blocks that were not written
+// with -write-mode=insert or insert-on-conflict-do-nothing because their key
+// already existed. A duplicate key fails a whole -write-mode=insert batch.
+var numDuplicates uint64
+
+// numUpdateMisses keeps a global count of the blocks that were not written
+// with -write-mode=update because their key did not exist.
+var numUpdateMisses uint64
+
+// skippedBlocks returns the number of blocks that were not written because of
+// duplicate keys or missing update keys. They count towards -max-ops, so that
+// a worker whose writes all hit existing or missing keys still stops.
+func skippedBlocks() uint64 {
+	return atomic.LoadUint64(&numDuplicates) + atomic.LoadUint64(&numUpdateMisses)
+}
+
+// uniqueViolation is the SQLSTATE of duplicate key errors.
+const uniqueViolation = "23505"
+
+func isDuplicateKeyError(err error) bool {
+	if pqErr, ok := err.(*pq.Error); ok {
+		return pqErr.Code == uniqueViolation
+	}
+	return false
+}
+
 const (
 	minLatency = 100 * time.Microsecond
 	maxLatency = 10 * time.Second
@@ -104,6 +160,13 @@ func clampLatency(d, min, max time.Duration) time.Duration {
 	return d
 }
 
+// workerSeed returns the seed for the random number generator of the ith
+// worker, derived from -seed so that each worker draws the same values from
+// run to run.
+func workerSeed(i int) int64 {
+	return *seed + int64(i) + 1
+}
+
 type sequence struct {
 	val  int64
 	seed int64
@@ -135,10 +198,10 @@ type hashGenerator struct {
 	buf    [sha1.Size]byte
 }
 
-func newHashGenerator(seq *sequence) *hashGenerator {
+func newHashGenerator(seq *sequence, seed int64) *hashGenerator {
 	return &hashGenerator{
 		seq:    seq,
-		random: rand.New(rand.NewSource(int64(time.Now().UnixNano()))),
+		random: rand.New(rand.NewSource(seed)),
 		hasher: sha1.New(),
 	}
 }
@@ -173,10 +236,10 @@ type sequentialGenerator struct {
 	random *rand.Rand
 }
 
-func newSequentialGenerator(seq *sequence) *sequentialGenerator {
+func newSequentialGenerator(seq *sequence, seed int64) *sequentialGenerator {
 	return &sequentialGenerator{
 		seq:    seq,
-		random: rand.New(rand.NewSource(int64(time.Now().UnixNano()))),
+		random: rand.New(rand.NewSource(seed)),
 	}
 }
 
@@ -199,15 +262,14 @@ func (g *sequentialGenerator) rand() *rand.Rand {
 func randomBlock(r *rand.Rand) []byte {
 	blockSize := r.Intn(*maxBlockSizeBytes-*minBlockSizeBytes) + *minBlockSizeBytes
 	blockData := make([]byte, blockSize)
-	for i := range blockData {
-		blockData[i] = byte(r.Int() & 0xff)
-	}
+	valueGenerators[*valueType](r, blockData)
 	return blockData
 }
 
 type database interface {
 	read(key int64) error
-	write(count int, g generator) error
+	// write writes count blocks and returns the number actually written.
+	write(count int, g generator) (int, error)
 	clone() database
 }
 
@@ -245,125 +307,351 @@ func (b *blocker) run(errCh chan<- error, wg *sync.WaitGroup, limiter *rate.Limi
 
 		start := time.Now()
 		var err error
+		n := *batch
 		if b.gen.rand().Intn(100) < *readPercent {
 			err = b.db.read(b.gen.readKey())
 		} else {
-			err = b.db.write(*batch, b.gen)
+			n, err = b.db.write(*batch, b.gen)
 		}
 		if err != nil {
 			errCh <- err
 			continue
 		}
+		if n == 0 {
+			// Nothing was written, so this is not counted as an operation.
+			if *maxOps > 0 && atomic.LoadUint64(&numOps)+skippedBlocks() >= *maxOps {
+				return
+			}
+			continue
+		}
 		elapsed := clampLatency(time.Since(start), minLatency, maxLatency)
 		b.latency.Lock()
 		if err := b.latency.Current.RecordValue(elapsed.Nanoseconds()); err != nil {
 			log.Fatal(err)
 		}
 		b.latency.Unlock()
-		v := atomic.AddUint64(&numOps, uint64(*batch))
-		if *maxOps > 0 && v >= *maxOps {
+		v := atomic.AddUint64(&numOps, uint64(n))
+		if *maxOps > 0 && v+skippedBlocks() >= *maxOps {
 			return
 		}
 	}
 }
 
+// compositePKPrefixes is the number of distinct values of the leading
+// primary key column with -composite-pk.
+const compositePKPrefixes = 16
+
+// pkPrefix returns the leading primary key column of the block with key k
+// with -composite-pk. Hashed keys may be negative, so the key is treated as
+// unsigned to keep the prefix in [0, compositePKPrefixes).
+func pkPrefix(k int64) int64 {
+	return int64(uint64(k) % compositePKPrefixes)
+}
+
+// kvColumns returns the columns of test.kv written for each block.
+func kvColumns() []string {
+	var cols []string
+	if *compositePK {
+		cols = append(cols, "p")
+	}
+	cols = append(cols, "k", "v")
+	for i := 1; i <= *secondaryIndexes; i++ {
+		cols = append(cols, fmt.Sprintf("s%d", i))
+	}
+	return cols
+}
+
+// appendKVArgs appends the values of kvColumns for the block with key k and
+// value v to args. The secondary columns are derived from the key so that
+// -index-reads can find the block.
+func appendKVArgs(args []interface{}, k int64, v []byte) []interface{} {
+	if *compositePK {
+		args = append(args, pkPrefix(k))
+	}
+	args = append(args, k, v)
+	for i := 1; i <= *secondaryIndexes; i++ {
+		args = append(args, k+int64(i))
+	}
+	return args
+}
+
 type cockroach struct {
-	db        *sql.DB
-	readStmt  *sql.Stmt
-	writeStmt *sql.Stmt
+	conns *sqlConns
+	// conn is the worker's connection. It is opened on first use and, with
+	// -reconnect-interval, replaced by a new one once reconnectAt passes.
+	conn        *kvConn
+	reconnectAt time.Time
+}
+
+// getConn returns the worker's connection, replacing it first if it is due.
+func (c *cockroach) getConn() (*kvConn, error) {
+	if c.conn != nil && (*reconnectInterval == 0 || time.Now().Before(c.reconnectAt)) {
+		return c.conn, nil
+	}
+	if c.conn != nil {
+		if err := c.conns.put(c.conn); err != nil {
+			return nil, err
+		}
+		c.conn = nil
+	}
+	conn, err := c.conns.get()
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	c.reconnectAt = time.Now().Add(*reconnectInterval)
+	return conn, nil
 }
 
 func (c *cockroach) read(k int64) error {
+	conn, err := c.getConn()
+	if err != nil {
+		return err
+	}
+	var args []interface{}
+	switch {
+	case *indexReads:
+		args = []interface{}{k + 1}
+	case *compositePK:
+		args = []interface{}{pkPrefix(k), k}
+	default:
+		args = []interface{}{k}
+	}
 	var v []byte
-	if err := c.readStmt.QueryRow(k).Scan(&k, &v); err != nil && err != sql.ErrNoRows {
+	if err := conn.readStmt.QueryRow(args...).Scan(&k, &v); err != nil && err != sql.ErrNoRows {
 		return err
 	}
 	return nil
 }
 
-func (c *cockroach) write(count int, g generator) error {
-	const argCount = 2
-	args := make([]interface{}, argCount*count)
+func (c *cockroach) write(count int, g generator) (int, error) {
+	conn, err := c.getConn()
+	if err != nil {
+		return 0, err
+	}
+	if *writeMode == "update" {
+		// The UPDATE statement changes a single block.
+		var written int
+		for i := 0; i < count; i++ {
+			k := g.readKey()
+			args := []interface{}{randomBlock(g.rand()), k}
+			if *compositePK {
+				args = append(args, pkPrefix(k))
+			}
+			res, err := conn.writeStmt.Exec(args...)
+			if err != nil {
+				return written, err
+			}
+			rows, err := res.RowsAffected()
+			if err != nil {
+				return written, err
+			}
+			if rows == 0 {
+				atomic.AddUint64(&numUpdateMisses, 1)
+			}
+			written += int(rows)
+		}
+		return written, nil
+	}
+
+	args := make([]interface{}, 0, len(kvColumns())*count)
 	for i := 0; i < count; i++ {
-		j := i * argCount
-		args[j+0] = g.writeKey()
-		args[j+1] = randomBlock(g.rand())
+		args = appendKVArgs(args, g.writeKey(), randomBlock(g.rand()))
 	}
-	// TODO(peter): The key generation is not guaranteed unique. Consider using
-	// UPSERT, though initial tests show that is half the speed. Or perhaps
-	// ignoring duplicate key violation errors.
-	_, err := c.writeStmt.Exec(args...)
-	return err
+	// The generated keys are not guaranteed to be unique.
+	res, err := conn.writeStmt.Exec(args...)
+	if err != nil {
+		if *writeMode == "insert" && isDuplicateKeyError(err) {
+			atomic.AddUint64(&numDuplicates, uint64(count))
+			return 0, nil
+		}
+		return 0, err
+	}
+	if *writeMode != "insert-on-conflict-do-nothing" {
+		return count, nil
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	atomic.AddUint64(&numDuplicates, uint64(count)-uint64(rows))
+	return int(rows), nil
 }
 
 func (c *cockroach) clone() database {
-	return c
+	return &cockroach{conns: c.conns}
+}
+
+// kvPrimaryKey returns the primary key columns of test.kv.
+func kvPrimaryKey() string {
+	if *compositePK {
+		return "p, k"
+	}
+	return "k"
 }
 
-func setupCockroach(parsedURL *url.URL) (database, error) {
-	// Open connection to server and create a database.
-	db, dbErr := sql.Open("postgres", parsedURL.String())
-	if dbErr != nil {
-		return nil, dbErr
+// createKVStmts returns the statements that create test.kv, with the columns
+// and indexes selected by the schema flags. Cockroach declares the indexes
+// in the CREATE TABLE statement, while Postgres creates them separately.
+func createKVStmts() []string {
+	bytesType := "BYTES"
+	if *dialect == "postgres" {
+		bytesType = "BYTEA"
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("CREATE TABLE IF NOT EXISTS test.kv (\n")
+	if *compositePK {
+		buf.WriteString("  p BIGINT NOT NULL,\n")
+	}
+	fmt.Fprintf(&buf, "  k BIGINT NOT NULL,\n  v %s NOT NULL,\n", bytesType)
+	for i := 1; i <= *secondaryIndexes; i++ {
+		fmt.Fprintf(&buf, "  s%d BIGINT NOT NULL,\n", i)
+	}
+	fmt.Fprintf(&buf, "  PRIMARY KEY (%s)", kvPrimaryKey())
+	if *dialect == "postgres" {
+		buf.WriteString("\n)")
+		stmts := []string{buf.String()}
+		for i := 1; i <= *secondaryIndexes; i++ {
+			stmts = append(stmts, fmt.Sprintf("CREATE INDEX IF NOT EXISTS kv_s%d_idx ON test.kv (s%d)", i, i))
+		}
+		return stmts
+	}
+	for i := 1; i <= *secondaryIndexes; i++ {
+		fmt.Fprintf(&buf, ",\n  INDEX (s%d)", i)
+		if *storingIndexes {
+			buf.WriteString(" STORING (v)")
+		}
 	}
-	if _, err := db.Exec("CREATE DATABASE IF NOT EXISTS test"); err != nil {
+	buf.WriteString("\n)")
+	return []string{buf.String()}
+}
+
+func setupCockroach(parsedURLs []*url.URL) (database, error) {
+	var urls []string
+	for _, u := range parsedURLs {
+		urls = append(urls, u.String())
+	}
+
+	// Open connection to the first server and create the table.
+	db, err := openDB(urls[0], 1)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	if err := setupCockroachTable(db); err != nil {
+		return nil, err
+	}
+
+	// The workers' connections are opened once the table exists, as the
+	// statements are prepared on them.
+	conns, err := newSQLConns(urls)
+	if err != nil {
 		return nil, err
 	}
+	return &cockroach{conns: conns}, nil
+}
 
-	// Allow a maximum of concurrency+1 connections to the database.
-	db.SetMaxOpenConns(*concurrency + 1)
-	db.SetMaxIdleConns(*concurrency + 1)
+// setupCockroachTable creates test.kv, dropping it first if -drop was
+// specified, and splits it if -splits was specified.
+func setupCockroachTable(db *sql.DB) error {
+	createTest := "CREATE DATABASE IF NOT EXISTS test"
+	if *dialect == "postgres" {
+		createTest = "CREATE SCHEMA IF NOT EXISTS test"
+	}
+	if _, err := db.Exec(createTest); err != nil {
+		return err
+	}
 
 	if *drop {
 		if _, err := db.Exec(`DROP TABLE IF EXISTS test.kv`); err != nil {
-			return nil, err
+			return err
 		}
 	}
 
-	if _, err := db.Exec(`
-	CREATE TABLE IF NOT EXISTS test.kv (
-	  k BIGINT NOT NULL PRIMARY KEY,
-	  v BYTES NOT NULL
-	)`); err != nil {
-		return nil, err
+	for _, stmt := range createKVStmts() {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
 	}
 
 	if *splits > 0 {
-		r := rand.New(rand.NewSource(int64(time.Now().UnixNano())))
-		g := newHashGenerator(&sequence{val: *writeSeq, seed: *seqSeed})
+		r := rand.New(rand.NewSource(*seed))
+		g := newHashGenerator(&sequence{val: *writeSeq, seed: *seed}, *seed)
 		for i := 0; i < *splits; i++ {
-			if _, err := db.Exec(`ALTER TABLE test.kv SPLIT AT VALUES ($1)`, g.hash(r.Int63())); err != nil {
-				return nil, err
+			k := g.hash(r.Int63())
+			var err error
+			if *compositePK {
+				_, err = db.Exec(`ALTER TABLE test.kv SPLIT AT VALUES ($1, $2)`, pkPrefix(k), k)
+			} else {
+				_, err = db.Exec(`ALTER TABLE test.kv SPLIT AT VALUES ($1)`, k)
+			}
+			if err != nil {
+				return err
 			}
 		}
 
 		if _, err := db.Exec(`ALTER TABLE test.kv SCATTER`); err != nil {
-			return nil, err
+			return err
 		}
 	}
 
-	readStmt, err := db.Prepare(`SELECT k, v FROM test.kv WHERE k = $1`)
-	if err != nil {
-		return nil, err
+	return nil
+}
+
+// readKVStmt returns the statement that reads a block.
+func readKVStmt() string {
+	switch {
+	case *indexReads:
+		return `SELECT k, v FROM test.kv WHERE s1 = $1`
+	case *compositePK:
+		return `SELECT k, v FROM test.kv WHERE p = $1 AND k = $2`
+	default:
+		return `SELECT k, v FROM test.kv WHERE k = $1`
 	}
+}
 
+// writeKVStmt returns the statement that writes -batch blocks, or a single
+// block with -write-mode=update.
+func writeKVStmt() string {
+	if *writeMode == "update" {
+		if *compositePK {
+			return `UPDATE test.kv SET v = $1 WHERE k = $2 AND p = $3`
+		}
+		return `UPDATE test.kv SET v = $1 WHERE k = $2`
+	}
+
+	cols := kvColumns()
 	var buf bytes.Buffer
-	buf.WriteString(`UPSERT INTO test.kv (k, v) VALUES`)
+	if *writeMode == "upsert" && *dialect == "cockroach" {
+		buf.WriteString("UPSERT")
+	} else {
+		buf.WriteString("INSERT")
+	}
+	fmt.Fprintf(&buf, ` INTO test.kv (%s) VALUES`, strings.Join(cols, ", "))
 
 	for i := 0; i < *batch; i++ {
-		j := i * 2
 		if i > 0 {
-			buf.WriteString(", ")
+			buf.WriteString(",")
 		}
-		fmt.Fprintf(&buf, ` ($%d, $%d)`, j+1, j+2)
+		buf.WriteString(" (")
+		for j := range cols {
+			if j > 0 {
+				buf.WriteString(", ")
+			}
+			fmt.Fprintf(&buf, "$%d", i*len(cols)+j+1)
+		}
+		buf.WriteString(")")
 	}
-
-	writeStmt, err := db.Prepare(buf.String())
-	if err != nil {
-		return nil, err
+	switch {
+	case *writeMode == "insert-on-conflict-do-nothing":
+		buf.WriteString(" ON CONFLICT DO NOTHING")
+	case *writeMode == "upsert" && *dialect == "postgres":
+		// Postgres has no UPSERT. The other columns are derived from the key,
+		// so only the value changes.
+		fmt.Fprintf(&buf, " ON CONFLICT (%s) DO UPDATE SET v = excluded.v", kvPrimaryKey())
 	}
-
-	return &cockroach{db: db, readStmt: readStmt, writeStmt: writeStmt}, nil
+	return buf.String()
 }
 
 type mongoBlock struct {
@@ -390,7 +678,7 @@ func (m *mongo) read(key int64) error {
 	return nil
 }
 
-func (m *mongo) write(count int, g generator) error {
+func (m *mongo) write(count int, g generator) (int, error) {
 	docs := make([]interface{}, count)
 	for i := 0; i < count; i++ {
 		docs[i] = &mongoBlock{
@@ -399,7 +687,10 @@ func (m *mongo) write(count int, g generator) error {
 		}
 	}
 
-	return m.kv.Insert(docs...)
+	if err := m.kv.Insert(docs...); err != nil {
+		return 0, err
+	}
+	return count, nil
 }
 
 func (m *mongo) clone() database {
@@ -444,7 +735,7 @@ func (c *cassandra) read(key int64) error {
 	return nil
 }
 
-func (c *cassandra) write(count int, g generator) error {
+func (c *cassandra) write(count int, g generator) (int, error) {
 	const insertBlockStmt = "INSERT INTO test.kv (k, v) VALUES (?, ?); "
 
 	var buf bytes.Buffer
@@ -460,7 +751,10 @@ func (c *cassandra) write(count int, g generator) error {
 	}
 
 	buf.WriteString("APPLY BATCH;")
-	return c.session.Query(buf.String(), args...).Exec()
+	if err := c.session.Query(buf.String(), args...).Exec(); err != nil {
+		return 0, err
+	}
+	return count, nil
 }
 
 func (c *cassandra) clone() database {
@@ -503,17 +797,30 @@ CREATE TABLE IF NOT EXISTS test.kv(
 
 // setupDatabase performs initial setup for the example, creating a database and
 // with a single table. If the desired table already exists on the cluster, the
-// existing table will be dropped.
-func setupDatabase(dbURL string) (database, error) {
-	parsedURL, err := url.Parse(dbURL)
-	if err != nil {
-		return nil, err
+// existing table will be dropped. The workers of SQL databases are spread
+// across all of the URLs, while the other databases only use the first.
+func setupDatabase(dbURLs []string) (database, error) {
+	var parsedURLs []*url.URL
+	for _, dbURL := range dbURLs {
+		parsedURL, err := url.Parse(dbURL)
+		if err != nil {
+			return nil, err
+		}
+		if *dialect != "postgres" {
+			parsedURL.Path = "test"
+		}
+		parsedURLs = append(parsedURLs, parsedURL)
+	}
+	parsedURL := parsedURLs[0]
+	for _, u := range parsedURLs[1:] {
+		if u.Scheme != parsedURL.Scheme {
+			return nil, fmt.Errorf("URLs must all be for the same database: %s and %s", parsedURL.Scheme, u.Scheme)
+		}
 	}
-	parsedURL.Path = "test"
 
 	switch parsedURL.Scheme {
 	case "postgres", "postgresql":
-		return setupCockroach(parsedURL)
+		return setupCockroach(parsedURLs)
 	case "mongodb":
 		return setupMongo(parsedURL)
 	case "cassandra":
@@ -525,7 +832,7 @@ func setupDatabase(dbURL string) (database, error) {
 
 var usage = func() {
 	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
-	fmt.Fprintf(os.Stderr, "  %s <db URL>\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s <db URL> [<db URL>...]\n\n", os.Args[0])
 	flag.PrintDefaults()
 }
 
@@ -533,9 +840,9 @@ func main() {
 	flag.Usage = usage
 	flag.Parse()
 
-	dbURL := "postgres://root@localhost:26257/photos?sslmode=disable"
-	if flag.NArg() == 1 {
-		dbURL = flag.Arg(0)
+	dbURLs := []string{"postgres://root@localhost:26257/photos?sslmode=disable"}
+	if flag.NArg() > 0 {
+		dbURLs = flag.Args()
 	}
 
 	if *concurrency < 1 {
@@ -546,15 +853,64 @@ func main() {
 		log.Fatalf("Value of 'max-block-bytes' (%d) must be greater than or equal to value of 'min-block-bytes' (%d)", max, min)
 	}
 
+	if _, ok := valueGenerators[*valueType]; !ok {
+		log.Fatalf("Unknown value type %q", *valueType)
+	}
+
+	if *valueCompressibility < 1 {
+		log.Fatalf("Value of 'value-compressibility' flag (%g) must be greater than or equal to 1", *valueCompressibility)
+	}
+
+	if *secondaryIndexes < 0 {
+		log.Fatalf("Value of 'secondary-indexes' flag (%d) must be greater than or equal to 0", *secondaryIndexes)
+	}
+
+	if (*storingIndexes || *indexReads) && *secondaryIndexes == 0 {
+		log.Fatalf("'storing-indexes' and 'index-reads' require 'secondary-indexes'")
+	}
+
+	if *urlBalance != "round-robin" && *urlBalance != "random" {
+		log.Fatalf("Unknown URL balancing %q", *urlBalance)
+	}
+
+	if *reconnectInterval > 0 && !*connPerWorker {
+		log.Fatalf("'reconnect-interval' requires 'conn-per-worker'")
+	}
+
+	switch *writeMode {
+	case "upsert", "insert", "insert-on-conflict-do-nothing", "update":
+	default:
+		log.Fatalf("Unknown write mode %q", *writeMode)
+	}
+
+	if *writeMode == "update" && *writeSeq <= 0 {
+		log.Fatalf("'write-mode=update' requires 'write-seq' to be the final write sequence of an earlier run")
+	}
+	if *writeMode == "update" && *cycleLength != math.MaxInt64 {
+		log.Fatalf("'write-mode=update' and 'cycle-length' cannot both be set")
+	}
+
+	switch *dialect {
+	case "cockroach":
+	case "postgres":
+		if *splits > 0 || *storingIndexes {
+			log.Fatalf("'splits' and 'storing-indexes' are not supported by postgres")
+		}
+	default:
+		log.Fatalf("Unknown dialect %q", *dialect)
+	}
+
 	if *sequential && *splits > 0 {
 		log.Fatalf("'sequential' and 'splits' cannot both be enabled")
 	}
 
+	log.Printf("using seed %d", *seed)
+
 	var db database
 	{
 		var err error
 		for err == nil || *tolerateErrors {
-			db, err = setupDatabase(dbURL)
+			db, err = setupDatabase(dbURLs)
 			if err == nil {
 				break
 			}
@@ -576,15 +932,15 @@ func main() {
 	var lastOps uint64
 	writers := make([]*blocker, *concurrency)
 
-	seq := &sequence{val: *writeSeq, seed: *seqSeed}
+	seq := &sequence{val: *writeSeq, seed: *seed}
 	errCh := make(chan error)
 	var wg sync.WaitGroup
 	for i := range writers {
 		wg.Add(1)
 		if *sequential {
-			writers[i] = newBlocker(db.clone(), newSequentialGenerator(seq))
+			writers[i] = newBlocker(db.clone(), newSequentialGenerator(seq, workerSeed(i)))
 		} else {
-			writers[i] = newBlocker(db.clone(), newHashGenerator(seq))
+			writers[i] = newBlocker(db.clone(), newHashGenerator(seq, workerSeed(i)))
 		}
 		go writers[i].run(errCh, &wg, limiter)
 	}
@@ -691,6 +1047,14 @@ func main() {
 				time.Duration(p95).Seconds()*1000,
 				time.Duration(p99).Seconds()*1000,
 				time.Duration(pMax).Seconds()*1000)
+			switch *writeMode {
+			case "insert", "insert-on-conflict-do-nothing":
+				fmt.Printf("%d blocks not written because of duplicate keys\n\n", atomic.LoadUint64(&numDuplicates))
+			case "update":
+				fmt.Printf("%d blocks not written because their key did not exist\n\n", atomic.LoadUint64(&numUpdateMisses))
+			}
+			fmt.Printf("final write sequence %d (the -write-seq for a later -write-mode=update run)\n\n",
+				atomic.LoadInt64(&seq.val))
 			return
 		}
 	}
@@ -0,0 +1,87 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+//
+// The value generators control how compressible the written blocks are, so
+// that the effect of the storage engine's compression can be measured.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+)
+
+// valueGenerator fills buf with a value.
+type valueGenerator func(r *rand.Rand, buf []byte)
+
+var valueGenerators = map[string]valueGenerator{
+	"random": randomValue,
+	"text":   textValue,
+	"json":   jsonValue,
+	"zeros":  zeroValue,
+}
+
+// randomValue fills the first 1/-value-compressibility of buf with random
+// bytes and leaves the rest zero, so that the value compresses by roughly
+// -value-compressibility.
+func randomValue(r *rand.Rand, buf []byte) {
+	n := int(float64(len(buf))/(*valueCompressibility) + 0.5)
+	// Read never returns an error.
+	_, _ = r.Read(buf[:n])
+}
+
+// words is the vocabulary of the text and json values.
+var words = []string{
+	"the", "quick", "brown", "fox", "jumps", "over", "lazy", "dog", "lorem",
+	"ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit",
+	"sed", "do", "eiusmod", "tempor", "incididunt", "ut", "labore", "et",
+	"dolore", "magna", "aliqua", "enim", "ad", "minim", "veniam", "quis",
+}
+
+// textValue fills buf with space separated words, which compress about as
+// well as English text.
+func textValue(r *rand.Rand, buf []byte) {
+	for i := 0; i < len(buf); {
+		i += copy(buf[i:], words[r.Intn(len(words))])
+		if i < len(buf) {
+			buf[i] = ' '
+			i++
+		}
+	}
+}
+
+// jsonValue fills buf with a JSON document of the kind stored by web
+// applications, truncated to fit.
+func jsonValue(r *rand.Rand, buf []byte) {
+	var b bytes.Buffer
+	b.Grow(len(buf))
+	fmt.Fprintf(&b, `{"id": %d`, r.Int63())
+	for i := 0; b.Len() < len(buf); i++ {
+		switch i % 3 {
+		case 0:
+			fmt.Fprintf(&b, `, "name%d": "%s %s"`, i, words[r.Intn(len(words))], words[r.Intn(len(words))])
+		case 1:
+			fmt.Fprintf(&b, `, "count%d": %d`, i, r.Intn(1000))
+		case 2:
+			fmt.Fprintf(&b, `, "enabled%d": %t`, i, r.Intn(2) == 0)
+		}
+	}
+	b.WriteString("}")
+	copy(buf, b.Bytes())
+}
+
+// zeroValue leaves buf zero filled.
+func zeroValue(r *rand.Rand, buf []byte) {}
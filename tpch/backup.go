@@ -0,0 +1,67 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// backupLocation returns the location of the backup for the current scale
+// factor under the given base location, for example
+// nodelocal:///tpch/scalefactor-10.
+func backupLocation(base string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid backup location %q", base)
+	}
+	u.Path = path.Join(u.Path, fmt.Sprintf("scalefactor-%d", *scaleFactor))
+	return u.String(), nil
+}
+
+// runBackup backs up the tpch database to the backup for the current scale
+// factor under the given base location.
+func runBackup(db *sql.DB, base string) error {
+	loc, err := backupLocation(base)
+	if err != nil {
+		return err
+	}
+	log.Printf("backing up to %s", loc)
+	start := time.Now()
+	if _, err := db.Exec("BACKUP DATABASE tpch TO $1", loc); err != nil {
+		return err
+	}
+	log.Printf("backup complete after %4.2f seconds", time.Since(start).Seconds())
+	return nil
+}
+
+// runRestore restores the tpch tables from the backup at the given location,
+// then checks that every table has the expected number of rows.
+func runRestore(db *sql.DB, loc string) error {
+	log.Printf("restoring from %s", loc)
+	start := time.Now()
+	if _, err := db.Exec("RESTORE tpch.* FROM $1", loc); err != nil {
+		return err
+	}
+	log.Printf("restore complete after %4.2f seconds", time.Since(start).Seconds())
+	return verifyRowCounts(db)
+}
@@ -0,0 +1,279 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+//
+// The benchmark runs the power and throughput tests described in section 5
+// of the TPC-H specification, and computes the Power@Size, Throughput@Size
+// and QphH@Size metrics.
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+const numQueries = 22
+
+// streamOrders are the query orderings for each query stream, from
+// Appendix A of the TPC-H specification. Stream 0 is used by the power test,
+// and streams 1 through S by the throughput test. The specification lists
+// 41 orderings, enough for the 40 query streams of the largest scale factor.
+var streamOrders = [...][numQueries]int{
+	{14, 2, 9, 20, 6, 17, 18, 8, 21, 13, 3, 22, 16, 4, 11, 15, 1, 10, 19, 5, 7, 12},
+	{21, 3, 18, 5, 11, 7, 6, 20, 17, 12, 16, 15, 13, 10, 2, 8, 14, 19, 9, 22, 1, 4},
+	{6, 17, 14, 16, 19, 10, 9, 2, 15, 8, 5, 22, 12, 7, 13, 18, 1, 4, 20, 3, 11, 21},
+	{8, 5, 4, 6, 17, 7, 1, 18, 22, 14, 9, 10, 15, 11, 20, 2, 21, 19, 13, 16, 12, 3},
+	{5, 21, 14, 19, 15, 17, 12, 6, 4, 9, 8, 16, 11, 2, 10, 18, 1, 13, 7, 22, 3, 20},
+	{21, 15, 4, 6, 7, 16, 19, 18, 14, 22, 11, 13, 3, 1, 2, 5, 8, 20, 12, 17, 10, 9},
+	{10, 3, 15, 13, 6, 8, 9, 7, 4, 11, 22, 18, 12, 1, 5, 16, 2, 14, 19, 20, 17, 21},
+	{18, 8, 20, 21, 2, 4, 22, 17, 1, 11, 9, 19, 3, 13, 5, 7, 10, 16, 6, 14, 15, 12},
+	{19, 1, 15, 17, 5, 8, 9, 12, 14, 7, 4, 3, 20, 16, 6, 22, 10, 13, 2, 21, 18, 11},
+	{8, 13, 2, 20, 17, 3, 6, 21, 18, 11, 19, 10, 15, 4, 22, 1, 7, 12, 9, 14, 5, 16},
+	{6, 15, 18, 17, 12, 1, 7, 2, 22, 13, 21, 10, 14, 9, 3, 16, 20, 19, 11, 4, 8, 5},
+	{15, 14, 18, 17, 10, 20, 16, 11, 1, 8, 4, 22, 5, 12, 3, 9, 21, 2, 13, 6, 19, 7},
+	{1, 7, 16, 17, 18, 22, 12, 6, 8, 9, 11, 4, 2, 5, 20, 21, 13, 10, 19, 3, 14, 15},
+	{21, 17, 7, 3, 1, 10, 12, 22, 9, 16, 6, 11, 2, 4, 5, 14, 8, 20, 13, 18, 15, 19},
+	{2, 9, 5, 4, 18, 1, 20, 15, 16, 17, 7, 21, 13, 14, 19, 8, 22, 11, 10, 3, 12, 6},
+	{16, 9, 17, 8, 14, 11, 10, 12, 6, 21, 7, 3, 15, 5, 22, 20, 1, 13, 19, 2, 4, 18},
+	{1, 3, 6, 5, 2, 16, 14, 22, 17, 20, 4, 9, 10, 11, 15, 8, 12, 19, 18, 13, 7, 21},
+	{3, 16, 5, 11, 21, 9, 2, 15, 10, 18, 17, 7, 8, 19, 14, 13, 1, 4, 22, 20, 6, 12},
+	{14, 4, 13, 5, 21, 11, 8, 6, 3, 17, 2, 20, 1, 19, 10, 9, 12, 18, 15, 7, 22, 16},
+	{4, 12, 22, 14, 5, 15, 16, 2, 8, 10, 17, 9, 21, 7, 3, 6, 13, 18, 11, 20, 19, 1},
+	{16, 15, 14, 13, 4, 22, 18, 19, 7, 1, 12, 17, 5, 10, 20, 3, 9, 21, 11, 2, 6, 8},
+	{20, 14, 21, 12, 15, 17, 4, 19, 13, 10, 11, 1, 16, 5, 18, 7, 8, 22, 9, 6, 3, 2},
+	{16, 14, 13, 2, 21, 10, 11, 4, 1, 22, 18, 12, 19, 5, 7, 8, 6, 3, 15, 20, 9, 17},
+	{18, 15, 9, 14, 12, 2, 8, 11, 22, 21, 16, 1, 6, 17, 5, 10, 19, 4, 20, 13, 3, 7},
+	{7, 3, 10, 14, 13, 21, 18, 6, 20, 4, 9, 8, 22, 15, 2, 1, 5, 12, 19, 17, 11, 16},
+	{18, 1, 13, 7, 16, 10, 14, 2, 19, 5, 21, 11, 22, 15, 8, 17, 20, 3, 4, 12, 6, 9},
+	{13, 2, 22, 5, 11, 21, 20, 14, 7, 10, 4, 9, 19, 18, 6, 3, 1, 8, 15, 12, 17, 16},
+	{14, 17, 21, 8, 2, 9, 6, 4, 5, 13, 22, 7, 15, 3, 1, 18, 16, 11, 10, 12, 20, 19},
+	{10, 22, 1, 12, 13, 18, 21, 20, 2, 14, 16, 7, 15, 3, 4, 17, 5, 19, 6, 8, 9, 11},
+	{10, 8, 9, 18, 12, 6, 1, 5, 20, 11, 17, 22, 16, 3, 13, 2, 15, 21, 14, 19, 7, 4},
+	{7, 17, 22, 5, 3, 10, 13, 18, 9, 1, 14, 15, 21, 19, 16, 12, 8, 6, 11, 20, 4, 2},
+	{2, 9, 21, 3, 4, 7, 1, 11, 16, 5, 20, 19, 18, 8, 17, 13, 10, 12, 15, 6, 14, 22},
+	{15, 12, 8, 4, 22, 13, 16, 17, 18, 3, 7, 5, 6, 1, 9, 11, 21, 10, 14, 20, 19, 2},
+	{15, 16, 2, 11, 17, 7, 5, 14, 20, 4, 21, 3, 10, 9, 12, 8, 13, 6, 18, 19, 22, 1},
+	{1, 13, 11, 3, 4, 21, 6, 14, 15, 22, 18, 9, 7, 5, 10, 20, 12, 16, 17, 8, 19, 2},
+	{14, 17, 22, 20, 8, 16, 5, 10, 1, 13, 2, 21, 12, 9, 4, 18, 3, 7, 6, 19, 15, 11},
+	{9, 17, 7, 4, 5, 13, 21, 18, 11, 3, 22, 1, 6, 16, 20, 14, 15, 10, 8, 2, 12, 19},
+	{13, 14, 5, 22, 19, 11, 9, 6, 18, 15, 8, 10, 7, 4, 17, 16, 3, 1, 12, 2, 21, 20},
+	{20, 5, 4, 14, 11, 1, 6, 16, 8, 22, 7, 3, 2, 12, 21, 19, 17, 13, 10, 15, 18, 9},
+	{3, 7, 14, 15, 6, 5, 21, 20, 18, 10, 4, 16, 19, 1, 13, 9, 8, 17, 11, 12, 22, 2},
+	{13, 15, 17, 1, 22, 11, 3, 4, 7, 20, 14, 21, 9, 8, 2, 18, 16, 6, 10, 12, 5, 19},
+}
+
+// minStreams returns the minimum number of query streams required by the
+// specification for the throughput test at the given scale factor.
+func minStreams(sf uint) int {
+	switch {
+	case sf < 10:
+		return 2
+	case sf < 30:
+		return 3
+	case sf < 100:
+		return 4
+	case sf < 300:
+		return 5
+	case sf < 1000:
+		return 6
+	case sf < 3000:
+		return 7
+	case sf < 10000:
+		return 8
+	case sf < 30000:
+		return 9
+	case sf < 100000:
+		return 10
+	default:
+		return 11
+	}
+}
+
+// runStream runs all 22 queries in the order given for the stream, and
+// returns the time taken by each query, indexed by query number.
+func runStream(ctx context.Context, db *sql.DB, stream int) ([numQueries + 1]time.Duration, error) {
+	var timings [numQueries + 1]time.Duration
+	r := rand.New(rand.NewSource(*seed + int64(stream)))
+	for _, query := range streamOrders[stream] {
+		start := time.Now()
+		numRows, err := runQuery(ctx, db, query, r, stream)
+		if err != nil {
+			return timings, errors.Wrapf(err, "stream %d: error running query %d", stream, query)
+		}
+		timings[query] = time.Since(start)
+		log.Printf("[stream %d] finished query %d: %d rows returned after %4.2f seconds\n",
+			stream, query, numRows, timings[query].Seconds())
+	}
+	return timings, nil
+}
+
+// runRefreshPair runs RF1 followed by RF2 with the given update set, and
+// returns the time taken by each.
+func runRefreshPair(db *sql.DB, set int) (rf1, rf2 time.Duration, err error) {
+	start := time.Now()
+	if err := runRefresh1(db, set); err != nil {
+		return 0, 0, err
+	}
+	rf1 = time.Since(start)
+	start = time.Now()
+	if err := runRefresh2(db, set); err != nil {
+		return rf1, 0, err
+	}
+	rf2 = time.Since(start)
+	log.Printf("[refresh] finished update set %d: RF1 %4.2f seconds, RF2 %4.2f seconds\n",
+		set, rf1.Seconds(), rf2.Seconds())
+	return rf1, rf2, nil
+}
+
+// runPowerTest runs RF1, then query stream 0, then RF2, and returns
+// Power@Size.
+func runPowerTest(ctx context.Context, db *sql.DB) (float64, error) {
+	log.Printf("starting power test")
+	start := time.Now()
+	if err := runRefresh1(db, 1); err != nil {
+		return 0, err
+	}
+	rf1 := time.Since(start)
+
+	timings, err := runStream(ctx, db, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	start = time.Now()
+	if err := runRefresh2(db, 1); err != nil {
+		return 0, err
+	}
+	rf2 := time.Since(start)
+
+	intervals := append(timings[1:], rf1, rf2)
+	return powerAtSize(intervals), nil
+}
+
+// powerAtSize computes Power@Size from the timing intervals of the 22
+// queries and 2 refresh functions of the power test. Following clause
+// 5.4.1.4, intervals shorter than 1/1000th of the longest are increased to
+// that value.
+func powerAtSize(intervals []time.Duration) float64 {
+	var longest float64
+	for _, d := range intervals {
+		longest = math.Max(longest, d.Seconds())
+	}
+	var sumLogs float64
+	for _, d := range intervals {
+		sumLogs += math.Log(math.Max(d.Seconds(), longest/1000))
+	}
+	geoMean := math.Exp(sumLogs / float64(len(intervals)))
+	return 3600 * float64(*scaleFactor) / geoMean
+}
+
+// runThroughputTest runs the given number of query streams concurrently with
+// a refresh stream that runs one RF1/RF2 pair per query stream, and returns
+// Throughput@Size.
+func runThroughputTest(ctx context.Context, db *sql.DB, streams int) (float64, error) {
+	log.Printf("starting throughput test with %d streams", streams)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, streams+1)
+	for i := 1; i <= streams; i++ {
+		wg.Add(1)
+		go func(stream int) {
+			defer wg.Done()
+			if _, err := runStream(ctx, db, stream); err != nil {
+				errCh <- err
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// Update set 1 is used by the power test.
+		for set := 2; set <= streams+1; set++ {
+			if _, _, err := runRefreshPair(db, set); err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(errCh)
+	if err := <-errCh; err != nil {
+		return 0, err
+	}
+
+	elapsed := time.Since(start).Seconds()
+	return float64(streams*numQueries) * 3600 / elapsed * float64(*scaleFactor), nil
+}
+
+// runBenchmark runs the power and/or throughput tests as selected by
+// *benchmark, and prints the resulting metrics.
+func runBenchmark(ctx context.Context, db *sql.DB) error {
+	streams := *numStreams
+	if streams == 0 {
+		streams = minStreams(*scaleFactor)
+	}
+	if streams < 1 || streams >= len(streamOrders) {
+		return errors.Errorf("-streams must be between 1 and %d", len(streamOrders)-1)
+	}
+
+	var refreshSets int
+	switch *benchmark {
+	case "power":
+		refreshSets = 1
+	case "throughput", "full":
+		refreshSets = streams + 1
+	default:
+		return errors.Errorf("unknown benchmark %q, must be one of power, throughput or full", *benchmark)
+	}
+	if err := checkRefreshSets(refreshSets); err != nil {
+		return err
+	}
+
+	var power, throughput float64
+	var err error
+	if *benchmark == "power" || *benchmark == "full" {
+		if power, err = runPowerTest(ctx, db); err != nil {
+			return errors.Wrap(err, "power test failed")
+		}
+	}
+	if *benchmark == "throughput" || *benchmark == "full" {
+		if throughput, err = runThroughputTest(ctx, db, streams); err != nil {
+			return errors.Wrap(err, "throughput test failed")
+		}
+	}
+
+	fmt.Printf("\nscale-factor__streams___Power@Size___Throughput@Size_______QphH@Size\n")
+	fmt.Printf("%12d %8d %12s %17s %15s\n", *scaleFactor, streams,
+		formatMetric(power), formatMetric(throughput),
+		formatMetric(math.Sqrt(power*throughput)))
+	return nil
+}
+
+// formatMetric formats a metric, or "-" if it was not measured.
+func formatMetric(v float64) string {
+	if v == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f", v)
+}
@@ -0,0 +1,235 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+//
+// The result checker compares query results against the reference answers
+// distributed with the TPC-H tools (answers/q1.out through answers/q22.out).
+// The reference answers are only valid for scale factor 1 and the
+// validation substitution parameters, which is what queryStmts uses.
+
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// checkTolerance is the relative difference allowed between a decimal
+// result and the reference answer. The TPC-H specification (clause 2.1.3.5)
+// requires sums, averages and ratios to be within 1% of the validation output.
+const checkTolerance = 0.01
+
+// answers caches the reference answers that have been read from
+// *answersDir, keyed by query number.
+var answers struct {
+	sync.Mutex
+	m map[int][][]string
+}
+
+// checkResults records whether each query's results matched its reference
+// answer the last time it was checked.
+var checkResults struct {
+	sync.Mutex
+	m map[int]error
+}
+
+// loadAnswer reads the reference answer for the given query. The answer
+// files are '|'-separated with a single header line, and columns are padded
+// with whitespace.
+func loadAnswer(query int) ([][]string, error) {
+	answers.Lock()
+	defer answers.Unlock()
+	if rows, ok := answers.m[query]; ok {
+		return rows, nil
+	}
+
+	filename := filepath.Join(*answersDir, fmt.Sprintf("q%d.out", query))
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			fmt.Printf("Error encountered when closing file '%s'\n: %s", filename, err)
+		}
+	}()
+
+	var rows [][]string
+	scanner := bufio.NewScanner(file)
+	for header := true; scanner.Scan(); header = false {
+		if header {
+			continue
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		rows = append(rows, fields)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if answers.m == nil {
+		answers.m = make(map[int][][]string)
+	}
+	answers.m[query] = rows
+	return rows, nil
+}
+
+// formatValue converts a value scanned from the database into the textual
+// form used by the reference answers.
+func formatValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return strings.TrimSpace(string(t))
+	case string:
+		return strings.TrimSpace(t)
+	case time.Time:
+		return t.Format("2006-01-02")
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// approximateTypes are the database types of the decimal columns, whose
+// values may differ from the reference answer by checkTolerance. Keys, counts
+// and every other column must match exactly.
+var approximateTypes = map[string]bool{
+	"NUMERIC": true,
+	"DECIMAL": true,
+	"FLOAT4":  true,
+	"FLOAT8":  true,
+	"FLOAT":   true,
+}
+
+// approximateColumns returns which of the columns of rows hold decimal
+// values.
+func approximateColumns(rows *sql.Rows) ([]bool, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	approx := make([]bool, len(types))
+	for i, t := range types {
+		approx[i] = approximateTypes[strings.ToUpper(t.DatabaseTypeName())]
+	}
+	return approx, nil
+}
+
+// valuesMatch reports whether a result value matches the expected reference
+// value. Values of decimal columns are compared with checkTolerance,
+// everything else must match exactly.
+func valuesMatch(expected, actual string, approx bool) bool {
+	if expected == actual {
+		return true
+	}
+	if !approx {
+		return false
+	}
+	e, err := strconv.ParseFloat(expected, 64)
+	if err != nil {
+		return false
+	}
+	a, err := strconv.ParseFloat(actual, 64)
+	if err != nil {
+		return false
+	}
+	diff := math.Abs(e - a)
+	// Allow rounding differences in the last reported digit as well as the
+	// relative error permitted by the specification.
+	return diff <= 0.01 || diff <= checkTolerance*math.Abs(e)
+}
+
+// checkAnswer compares the rows returned by a query against the reference
+// answer for that query, where approx marks the decimal columns. It returns a
+// descriptive error for the first mismatch found.
+func checkAnswer(query int, rows [][]string, approx []bool) error {
+	expected, err := loadAnswer(query)
+	if err != nil {
+		return errors.Wrapf(err, "could not load reference answer for query %d", query)
+	}
+	if len(rows) != len(expected) {
+		return errors.Errorf("expected %d rows, got %d", len(expected), len(rows))
+	}
+	for i := range expected {
+		if len(rows[i]) != len(expected[i]) {
+			return errors.Errorf("row %d: expected %d columns, got %d",
+				i+1, len(expected[i]), len(rows[i]))
+		}
+		for j := range expected[i] {
+			if !valuesMatch(expected[i][j], rows[i][j], approx[j]) {
+				return errors.Errorf("row %d, column %d: expected %q, got %q",
+					i+1, j+1, expected[i][j], rows[i][j])
+			}
+		}
+	}
+	return nil
+}
+
+// recordCheck records the outcome of checking a query's results. The first
+// mismatch of a query is kept, so that a later matching run of the same query
+// does not hide it.
+func recordCheck(query int, err error) {
+	checkResults.Lock()
+	defer checkResults.Unlock()
+	if checkResults.m == nil {
+		checkResults.m = make(map[int]error)
+	}
+	if prev, ok := checkResults.m[query]; !ok || prev == nil {
+		checkResults.m[query] = err
+	}
+}
+
+// reportChecks prints the per-query outcome of the result checks and returns
+// the number of queries whose results did not match.
+func reportChecks() int {
+	checkResults.Lock()
+	defer checkResults.Unlock()
+
+	var queries []int
+	for query := range checkResults.m {
+		queries = append(queries, query)
+	}
+	sort.Ints(queries)
+
+	var failed int
+	fmt.Println("\nquery__result")
+	for _, query := range queries {
+		if err := checkResults.m[query]; err != nil {
+			failed++
+			fmt.Printf("%5d  FAIL: %s\n", query, err)
+		} else {
+			fmt.Printf("%5d  ok\n", query)
+		}
+	}
+	return failed
+}
@@ -20,7 +20,9 @@ package main
 import (
 	"database/sql"
 	"fmt"
+	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cockroachdb/cockroach-go/crdb"
@@ -52,22 +54,20 @@ var tableNames = [...]string{
 	lineitem: "lineitem",
 }
 
-var createStmts = [...]string{
+// tableColumns are the column definitions of each table, which are combined
+// with the primary key and other clauses of the selected schema by
+// createTableStmt.
+var tableColumns = [...]string{
 	nation: `
-    CREATE TABLE nation  (
       n_nationkey       INTEGER NOT NULL,
       n_name            CHAR(25) NOT NULL,
       n_regionkey       INTEGER NOT NULL,
-      n_comment         VARCHAR(152)
-		);`,
+      n_comment         VARCHAR(152)`,
 	region: `
-    CREATE TABLE region  (
       r_regionkey       INTEGER NOT NULL,
       r_name            CHAR(25) NOT NULL,
-      r_comment         VARCHAR(152)
-    )`,
+      r_comment         VARCHAR(152)`,
 	part: `
-    CREATE TABLE part  (
       p_partkey         INTEGER NOT NULL,
       p_name            VARCHAR(55) NOT NULL,
       p_mfgr            CHAR(25) NOT NULL,
@@ -76,28 +76,22 @@ var createStmts = [...]string{
       p_size            INTEGER NOT NULL,
       p_container       CHAR(10) NOT NULL,
       p_retailprice     DECIMAL(15,2) NOT NULL,
-      p_comment         VARCHAR(23) NOT NULL
-    )`,
+      p_comment         VARCHAR(23) NOT NULL`,
 	supplier: `
-    CREATE TABLE supplier (
       s_suppkey         INTEGER NOT NULL,
       s_name            CHAR(25) NOT NULL,
       s_address         VARCHAR(40) NOT NULL,
       s_nationkey       INTEGER NOT NULL,
       s_phone           CHAR(15) NOT NULL,
       s_acctbal         DECIMAL(15,2) NOT NULL,
-      s_comment         VARCHAR(101) NOT NULL
-    )`,
+      s_comment         VARCHAR(101) NOT NULL`,
 	partsupp: `
-    CREATE TABLE partsupp (
       ps_partkey            INTEGER NOT NULL,
       ps_suppkey            INTEGER NOT NULL,
       ps_availqty           INTEGER NOT NULL,
       ps_supplycost         DECIMAL(15,2) NOT NULL,
-      ps_comment            VARCHAR(199) NOT NULL
-    )`,
+      ps_comment            VARCHAR(199) NOT NULL`,
 	customer: `
-	CREATE TABLE customer (
       c_custkey         INTEGER NOT NULL,
       c_name            VARCHAR(25) NOT NULL,
       c_address         VARCHAR(40) NOT NULL,
@@ -105,10 +99,8 @@ var createStmts = [...]string{
       c_phone           CHAR(15) NOT NULL,
       c_acctbal         DECIMAL(15,2)   NOT NULL,
       c_mktsegment      CHAR(10) NOT NULL,
-      c_comment         VARCHAR(117) NOT NULL
-    )`,
+      c_comment         VARCHAR(117) NOT NULL`,
 	orders: `
-    CREATE TABLE orders  (
       o_orderkey           INTEGER NOT NULL,
       o_custkey            INTEGER NOT NULL,
       o_orderstatus        CHAR(1) NOT NULL,
@@ -117,10 +109,8 @@ var createStmts = [...]string{
       o_orderpriority      CHAR(15) NOT NULL,
       o_clerk              CHAR(15) NOT NULL,
       o_shippriority       INTEGER NOT NULL,
-      o_comment            VARCHAR(79) NOT NULL
-    )`,
+      o_comment            VARCHAR(79) NOT NULL`,
 	lineitem: `
-    CREATE TABLE lineitem (
       l_orderkey      INTEGER NOT NULL,
       l_partkey       INTEGER NOT NULL,
       l_suppkey       INTEGER NOT NULL,
@@ -136,8 +126,7 @@ var createStmts = [...]string{
       l_receiptdate   DATE NOT NULL,
       l_shipinstruct  CHAR(25) NOT NULL,
       l_shipmode      CHAR(10) NOT NULL,
-      l_comment       VARCHAR(44) NOT NULL
-    )`,
+      l_comment       VARCHAR(44) NOT NULL`,
 }
 
 var dropStmts = [...]string{
@@ -235,7 +224,8 @@ func createTables(db *sql.DB) error {
 	if *verbose {
 		fmt.Println("Finished dropping tables. Creating tables")
 	}
-	for _, createStmt := range createStmts {
+	for t := table(0); t < numTables; t++ {
+		createStmt := getSchema().createTableStmt(t)
 		if *verbose {
 			fmt.Println("executing: ", createStmt)
 		}
@@ -263,23 +253,101 @@ func createIndexes(db *sql.DB) error {
 		fmt.Println("Creating indexes")
 	}
 
-	// TODO(cuongdo): Parallelize index creation.
-	for _, stmt := range createIndexStmts {
-		start := time.Now()
-		if *verbose {
-			fmt.Println("executing: ", stmt)
+	if query := getDialect().jobProgressQuery; query != "" {
+		stop := make(chan struct{})
+		defer close(stop)
+		go reportJobProgress(db, query, stop)
+	}
+
+	// The constraints are added once their indexes exist.
+	s := getSchema()
+	if err := execSchemaChanges(db, s.indexStmts); err != nil {
+		return err
+	}
+	return execSchemaChanges(db, s.constraintStmts)
+}
+
+// execSchemaChanges runs the given schema changes, up to *indexConcurrency
+// at a time, and logs the time each one took. It returns the first error
+// encountered once all of the schema changes have finished.
+func execSchemaChanges(db *sql.DB, stmts []string) error {
+	n := int(*indexConcurrency)
+	if n < 1 {
+		n = 1
+	}
+	sem := make(chan struct{}, n)
+	errCh := make(chan error, len(stmts))
+	var wg sync.WaitGroup
+	for _, stmt := range stmts {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(stmt string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			start := time.Now()
+			if *verbose {
+				fmt.Println("executing: ", stmt)
+			}
+			err := crdb.ExecuteTx(db, func(tx *sql.Tx) error {
+				_, execErr := db.Exec(stmt)
+				return execErr
+			})
+			if err != nil {
+				errCh <- errors.Wrapf(err, "failed to execute %q", stmt)
+				return
+			}
+			log.Printf("finished %q in %.2f s\n", stmt, time.Since(start).Seconds())
+		}(stmt)
+	}
+	wg.Wait()
+	close(errCh)
+	return <-errCh
+}
+
+// jobProgressInterval is how often reportJobProgress polls the running jobs.
+const jobProgressInterval = 10 * time.Second
+
+// reportJobProgress periodically runs the given query, which returns the
+// description and fraction completed of each running schema change, and logs
+// the progress of each until stop is closed.
+func reportJobProgress(db *sql.DB, query string, stop <-chan struct{}) {
+	ticker := time.NewTicker(jobProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
 		}
-		err := crdb.ExecuteTx(db, func(tx *sql.Tx) error {
-			_, execErr := db.Exec(stmt)
-			return execErr
-		})
+		rows, err := db.Query(query)
 		if err != nil {
-			return err
+			log.Printf("unable to poll job progress, giving up: %s", err)
+			return
 		}
-		if *verbose {
-			fmt.Printf("finished in %.2f s\n", time.Now().Sub(start).Seconds())
+		for rows.Next() {
+			var description string
+			var fraction float64
+			if err := rows.Scan(&description, &fraction); err != nil {
+				log.Printf("unable to poll job progress, giving up: %s", err)
+				_ = rows.Close()
+				return
+			}
+			log.Printf("[job] %5.1f%% %s\n", fraction*100, description)
 		}
+		_ = rows.Close()
 	}
+}
 
+// runPostLoad runs the dialect's post-load statements, such as collecting
+// table statistics.
+func runPostLoad(db *sql.DB) error {
+	for _, stmt := range getDialect().postLoadStmts {
+		if *verbose {
+			fmt.Println("executing: ", stmt)
+		}
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,134 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// dialect describes the differences between the databases tpch can run
+// against.
+type dialect struct {
+	// createDatabaseStmt creates the tpch database if it does not exist. If
+	// empty, the database named in the URL must already exist.
+	createDatabaseStmt string
+	// columnTypes maps the column types used in tableColumns to the types
+	// used by the database. Types that are not in the map are unchanged.
+	columnTypes map[string]string
+	// postLoadStmts are run after the data has been loaded and the indexes
+	// created.
+	postLoadStmts []string
+	// sessionSettings returns the session settings applied before every
+	// query, before any -session-setting flags.
+	sessionSettings func() []sessionSetting
+	// supportsBackup is true if the database supports BACKUP and RESTORE.
+	supportsBackup bool
+	// supportsInterleave is true if the database supports interleaved
+	// tables.
+	supportsInterleave bool
+	// jobProgressQuery returns the description and fraction completed of
+	// each running schema change. If empty, index creation progress is not
+	// reported.
+	jobProgressQuery string
+}
+
+var dialects = map[string]*dialect{
+	"cockroach": {
+		createDatabaseStmt: "CREATE DATABASE IF NOT EXISTS tpch",
+		sessionSettings: func() []sessionSetting {
+			if *distsql {
+				return []sessionSetting{{"DISTSQL", "always"}}
+			}
+			return []sessionSetting{{"DISTSQL", "off"}}
+		},
+		supportsBackup:     true,
+		supportsInterleave: true,
+		jobProgressQuery: `SELECT description, fraction_completed FROM [SHOW JOBS]
+			WHERE type = 'SCHEMA CHANGE' AND status = 'running'`,
+	},
+	"postgres": {
+		// INTEGER is 64 bits in Cockroach but only 32 bits in Postgres,
+		// where the order keys would overflow above scale factor 350.
+		columnTypes: map[string]string{"INTEGER": "BIGINT"},
+		// Postgres needs table statistics to plan the joins sensibly.
+		postLoadStmts:   []string{"ANALYZE"},
+		sessionSettings: func() []sessionSetting { return nil },
+	},
+}
+
+// getDialect returns the dialect selected by -dialect. The flag is
+// validated at startup.
+func getDialect() *dialect {
+	return dialects[*dialectName]
+}
+
+// columnDefs returns the column definitions of the given table, using the
+// dialect's column types.
+func (d *dialect) columnDefs(t table) string {
+	defs := tableColumns[t]
+	for from, to := range d.columnTypes {
+		defs = strings.Replace(defs, " "+from+" ", " "+to+" ", -1)
+	}
+	return defs
+}
+
+// sessionSetting is a session variable that is SET before every query.
+type sessionSetting struct {
+	name, value string
+}
+
+var settingNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.]*$`)
+
+// sessionSettingsFlag accumulates -session-setting flags.
+type sessionSettingsFlag []sessionSetting
+
+func (f *sessionSettingsFlag) String() string {
+	var parts []string
+	for _, s := range *f {
+		parts = append(parts, s.name+"="+s.value)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *sessionSettingsFlag) Set(v string) error {
+	parts := strings.SplitN(v, "=", 2)
+	if len(parts) != 2 {
+		return errors.Errorf("session setting %q must be of the form key=value", v)
+	}
+	name := strings.TrimSpace(parts[0])
+	if !settingNameRE.MatchString(name) {
+		return errors.Errorf("invalid session setting name %q", name)
+	}
+	*f = append(*f, sessionSetting{name: name, value: strings.TrimSpace(parts[1])})
+	return nil
+}
+
+// sessionPrefix returns the statements that configure the session, which are
+// prepended to every query. Settings from -session-setting are applied after
+// the dialect's own settings, so they take precedence.
+func sessionPrefix() string {
+	var buf bytes.Buffer
+	settings := append(getDialect().sessionSettings(), extraSessionSettings...)
+	for _, s := range settings {
+		fmt.Fprintf(&buf, "SET %s = '%s'; ", s.name, strings.Replace(s.value, "'", "''", -1))
+	}
+	return buf.String()
+}
@@ -0,0 +1,198 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+//
+// The plan capture mode saves the EXPLAIN output of each query to
+// <plan-dir>/q<N>.plan, and compares it against the plans previously saved
+// in another directory so that plan changes between builds are flagged.
+
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// explainText returns the SQL that explains the given query, using the
+// validation parameters so that plans are comparable between runs. Only the
+// SELECT statements are explained; any other statements, such as the view
+// creation in query 15, are run as-is.
+func explainText(query int, analyze bool) string {
+	explain := "EXPLAIN "
+	if analyze {
+		explain = "EXPLAIN ANALYZE "
+	}
+	var stmts []string
+	for _, stmt := range strings.Split(substituteParams(queryStmts[query], validationParams[query], 0), ";") {
+		stmt = strings.TrimSpace(stmt)
+		if stmt == "" {
+			continue
+		}
+		if strings.HasPrefix(strings.ToUpper(stmt), "SELECT") {
+			stmt = explain + stmt
+		}
+		stmts = append(stmts, stmt)
+	}
+	return sessionPrefix() + strings.Join(stmts, ";\n")
+}
+
+// capturePlan runs the given EXPLAIN statement and returns its output, one
+// line per row with the columns separated by tabs.
+func capturePlan(db *sql.DB, explain string) (string, error) {
+	rows, err := db.Query(explain)
+	if err != nil {
+		return "", err
+	}
+	results, err := scanRows(rows)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	for _, row := range results {
+		buf.WriteString(strings.TrimRight(strings.Join(row, "\t"), " \t"))
+		buf.WriteByte('\n')
+	}
+	return buf.String(), nil
+}
+
+// runExplain captures the plan of each query and saves it to *planDir. If
+// *planBaseline is set, each plan is compared with the plan saved there, and
+// the differences are printed. It returns the number of plans that changed.
+func runExplain(db *sql.DB, queries []int) (int, error) {
+	if err := os.MkdirAll(*planDir, 0755); err != nil {
+		return 0, err
+	}
+
+	var changed int
+	for _, query := range queries {
+		plan, err := capturePlan(db, explainText(query, false))
+		if err != nil {
+			return changed, errors.Wrapf(err, "error explaining query %d", query)
+		}
+		planFile := fmt.Sprintf("q%d.plan", query)
+		if err := ioutil.WriteFile(filepath.Join(*planDir, planFile), []byte(plan), 0644); err != nil {
+			return changed, err
+		}
+
+		if *explainAnalyze {
+			analyzed, err := capturePlan(db, explainText(query, true))
+			if err != nil {
+				return changed, errors.Wrapf(err, "error explaining query %d", query)
+			}
+			analyzeFile := filepath.Join(*planDir, fmt.Sprintf("q%d.analyze", query))
+			if err := ioutil.WriteFile(analyzeFile, []byte(analyzed), 0644); err != nil {
+				return changed, err
+			}
+		}
+
+		if *planBaseline == "" {
+			fmt.Printf("query %d: plan saved\n", query)
+			continue
+		}
+		baseline, err := ioutil.ReadFile(filepath.Join(*planBaseline, planFile))
+		if os.IsNotExist(err) {
+			fmt.Printf("query %d: no baseline plan\n", query)
+			continue
+		} else if err != nil {
+			return changed, err
+		}
+		if string(baseline) == plan {
+			fmt.Printf("query %d: plan unchanged\n", query)
+			continue
+		}
+		changed++
+		fmt.Printf("query %d: PLAN CHANGED\n", query)
+		for _, line := range diffLines(splitLines(string(baseline)), splitLines(plan)) {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+	return changed, nil
+}
+
+// probeQueries runs EXPLAIN for each of the given queries to find the ones
+// the database cannot plan, which are logged as unsupported. Unless
+// -allow-unsupported is set, they are left out of the returned list of
+// queries to run.
+func probeQueries(db *sql.DB, queries []int) (run []int, unsupported []int) {
+	for _, query := range queries {
+		if _, err := capturePlan(db, explainText(query, false)); err != nil {
+			log.Printf("query %d is unsupported: %s", query, err)
+			unsupported = append(unsupported, query)
+			if !*allowUnsupported {
+				continue
+			}
+		}
+		run = append(run, query)
+	}
+	return run, unsupported
+}
+
+func splitLines(s string) []string {
+	return strings.Split(strings.TrimRight(s, "\n"), "\n")
+}
+
+// diffLines returns a line diff between a and b, with removed lines prefixed
+// by "-", added lines by "+" and unchanged lines by " ". Plans are small, so
+// the quadratic longest common subsequence is fine.
+func diffLines(a, b []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and
+	// b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, " "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "-"+a[i])
+			i++
+		default:
+			diff = append(diff, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, "+"+b[j])
+	}
+	return diff
+}
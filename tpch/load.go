@@ -40,25 +40,9 @@ func doInserts(db *sql.DB, preamble string, inserts []string) error {
 	})
 }
 
-func insertTableFromFile(db *sql.DB, filename string, tableType table) error {
-	if *verbose {
-		fmt.Printf("Inserting table from file: %s\n", filename)
-	}
-	file, err := os.Open(filename)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err := file.Close(); err != nil {
-			fmt.Printf("Error encountered when closing file '%s'\n: %s", filename, err)
-		}
-	}()
-
-	scanner := bufio.NewScanner(file)
-	var numTotalInserts uint
-	inserts := make([]string, 0, *insertsPerTransaction)
-
-	var insertPreamble, insertValues string
+// insertStatement returns the INSERT statement preamble for the given table,
+// and the format string for a single row of values.
+func insertStatement(tableType table) (insertPreamble, insertValues string, err error) {
 	switch tableType {
 	case nation:
 		insertPreamble = `INSERT INTO nation (n_nationkey, n_name, n_regionkey, n_comment) VALUES`
@@ -96,8 +80,32 @@ func insertTableFromFile(db *sql.DB, filename string, tableType table) error {
 		insertValues = ` (%s, %s, %s, %s, %s, %s, %s, %s,
                       '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s')`
 	default:
-		return errors.Errorf("Unknown table type: %d", tableType)
+		return "", "", errors.Errorf("Unknown table type: %d", tableType)
+	}
+	return insertPreamble, insertValues, nil
+}
 
+func insertTableFromFile(db *sql.DB, filename string, tableType table) error {
+	if *verbose {
+		fmt.Printf("Inserting table from file: %s\n", filename)
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			fmt.Printf("Error encountered when closing file '%s'\n: %s", filename, err)
+		}
+	}()
+
+	scanner := bufio.NewScanner(file)
+	var numTotalInserts uint
+	inserts := make([]string, 0, *insertsPerTransaction)
+
+	insertPreamble, insertValues, err := insertStatement(tableType)
+	if err != nil {
+		return err
 	}
 
 	start := time.Now()
@@ -26,17 +26,21 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/cockroachdb/cockroach-go/crdb"
 	_ "github.com/lib/pq"
 	"github.com/pkg/errors"
+	"golang.org/x/net/context"
 )
 
 var verbose = flag.Bool("v", false, "Print verbose debug output")
@@ -45,16 +49,65 @@ var drop = flag.Bool("drop", false,
 var load = flag.Bool("load", false,
 	"Load data into the database from ")
 var restore = flag.String("restore", "",
-	"Restore data from the specified backup file. Cannot be used with load.")
+	"Restore data from the specified backup. Cannot be used with load.")
+var restoreBase = flag.String("restore-base", "",
+	"Restore data from the backup for -scale-factor under the specified location, as written by -backup. "+
+		"Cannot be used with load or restore.")
+var backup = flag.String("backup", "",
+	"Back up the loaded data to a backup for -scale-factor under the specified location, then exit.")
+var verify = flag.Bool("verify", false,
+	"Check the row counts, referential integrity and key ranges of the data before running queries.")
 var dataDir = flag.String("data-dir", "data",
 	"Source for data files to load from. Data must be generated using the DBGEN utility.")
-var distsql = flag.Bool("dist-sql", true, "Use DistSQL for query execution (default true)")
+var distsql = flag.Bool("dist-sql", true, "Use DistSQL for query execution (default true, cockroach only)")
+var schemaName = flag.String("schema", "default",
+	"Physical schema used by -load: default, spec (primary and foreign keys), "+
+		"interleaved (spec, with lineitem interleaved in orders) or minimal (primary keys only)")
+var allowUnsupported = flag.Bool("allow-unsupported", false,
+	"Run queries even if the database fails to EXPLAIN them at startup.")
+var dialectName = flag.String("dialect", "cockroach", "SQL dialect of the database: cockroach or postgres. "+
+	"postgres uses BIGINT for the INTEGER columns, expects the database in the URL to exist, runs ANALYZE "+
+	"after loading and does not support backups or interleaved tables. The indexes are the same for both.")
+var extraSessionSettings sessionSettingsFlag
 var scaleFactor = flag.Uint("scale-factor", 1, "The Scale Factor for the TPC-H benchmark")
+var indexConcurrency = flag.Uint("index-concurrency", 4,
+	"Number of indexes to create concurrently when loading data.")
 var insertsPerTransaction = flag.Uint("inserts-per-tx", 100, "Number of inserts to batch into a single transaction when loading data")
 var queries = flag.String("queries", "1,3,7,8,9,19", "Queries to run. Use a comma separated list of query numbers. Default: (1,3,7,8,9,19)")
 var loops = flag.Uint("loops", 1, "Number of times to run the queries (0 = run forever).")
 var concurrency = flag.Uint("concurrency", 1, "Number of queries to execute concurrently.")
 var maxErrors = flag.Uint64("max-errors", 1, "Number of query errors allowed before aborting (0 = unlimited).")
+var check = flag.Bool("check", false, "Compare query results against the TPC-H reference answers "+
+	"(scale factor 1 only, implies -fixed-params).")
+var seed = flag.Int64("seed", time.Now().UnixNano(), "Seed for the query substitution parameter generator.")
+var fixedParams = flag.Bool("fixed-params", false,
+	"Use the TPC-H validation substitution parameters for every query instead of drawing random ones.")
+var queryTimeout = flag.Duration("query-timeout", 0,
+	"Cancel queries that run for longer than this, recording them as timeouts (0 = no timeout).")
+var benchmark = flag.String("benchmark", "",
+	"Run the TPC-H power, throughput or full (power followed by throughput) test instead of looping over -queries.")
+var numStreams = flag.Int("streams", 0,
+	"Number of query streams in the throughput test (0 = the minimum required for the scale factor).")
+var refreshDir = flag.String("refresh-dir", "updates",
+	"Source for the refresh function update sets. Data must be generated using `dbgen -U`.")
+var generateRefresh = flag.Bool("generate-refresh", false,
+	"Generate the refresh function data in-process instead of reading update sets from -refresh-dir.")
+var refreshInterval = flag.Duration("refresh-interval", 0,
+	"Run a pair of refresh functions (RF1 and RF2) at this interval while the queries run (0 = never).")
+var explain = flag.Bool("explain", false,
+	"Save the EXPLAIN output of each query to -plan-dir instead of running the queries.")
+var explainAnalyze = flag.Bool("explain-analyze", false,
+	"Like -explain, but also save the EXPLAIN ANALYZE output of each query.")
+var planDir = flag.String("plan-dir", "plans", "Directory to save query plans to.")
+var planBaseline = flag.String("plan-baseline", "",
+	"Directory of previously saved query plans to compare against. Changed plans are reported.")
+var answersDir = flag.String("answers-dir", "answers",
+	"Directory containing the TPC-H reference answers (q1.out through q22.out) used by -check.")
+
+func init() {
+	flag.Var(&extraSessionSettings, "session-setting",
+		"Session setting to apply before every query, as key=value. May be repeated.")
+}
 
 // Flags for testing this load generator.
 var insertLimit = flag.Uint("insert-limit", 0, "Limit number of rows to be inserted from each file "+
@@ -79,12 +132,6 @@ func loadFile(dbURL string, datafile string, t table) error {
 	return nil
 }
 
-func runRestore(db *sql.DB, restoreLoc string) error {
-	restoreStmt := fmt.Sprintf("RESTORE tpch.* FROM '%s'", restoreLoc)
-	_, err := db.Exec(restoreStmt)
-	return err
-}
-
 // setupDatabase performs initial setup for the example, creating a database
 // with a single table. If the desired table already exists on the cluster, the
 // existing table will be dropped if the -drop flag was specified.
@@ -112,20 +159,45 @@ var usage = func() {
 	flag.PrintDefaults()
 }
 
-// loopQueries runs the given list of queries *loops times. id is used for
-// logging. Query errors are atomically added to errorCount, resulting in a
-// fatal error if we have more than *maxErrors errors.
-func loopQueries(id uint, db *sql.DB, queries []int, wg *sync.WaitGroup, errorCount *uint64) {
+// loopQueries runs the given list of queries *loops times, or until ctx is
+// cancelled. id is used for logging. Query errors are atomically added to
+// errorCount, resulting in a fatal error if we have more than *maxErrors
+// errors. The latency and outcome of each query is recorded in stats.
+func loopQueries(
+	ctx context.Context,
+	id uint,
+	db *sql.DB,
+	queries []int,
+	wg *sync.WaitGroup,
+	errorCount *uint64,
+	stats workerStats,
+) {
 	defer wg.Done()
+	// Each worker draws its substitution parameters from its own RNG, derived
+	// from *seed so that runs can be reproduced.
+	r := rand.New(rand.NewSource(*seed + int64(id)))
 	for i := uint(0); i < *loops || *loops == 0; i++ {
 		for _, query := range queries {
+			if ctx.Err() != nil {
+				return
+			}
 			if *verbose {
 				log.Printf("[%d] running query %d", id, query)
 			}
 			start := time.Now()
-			numRows, err := runQuery(db, query)
+			numRows, err := runQuery(ctx, db, query, r, int(id))
 			elapsed := time.Now().Sub(start)
+			if err == errQueryTimeout {
+				stats.recordTimeout(query)
+				log.Printf("[%d] query %d timed out after %4.2f seconds\n", id, query, elapsed.Seconds())
+				continue
+			}
 			if err != nil {
+				if ctx.Err() != nil {
+					// The query was cancelled because we are shutting down.
+					return
+				}
+				stats.recordError(query)
 				newErrorCount := atomic.AddUint64(errorCount, 1)
 				wrappedErr := errors.Wrapf(err, "[%d] error running query %d", id, query)
 				if newErrorCount < *maxErrors || *maxErrors == 0 {
@@ -135,6 +207,7 @@ func loopQueries(id uint, db *sql.DB, queries []int, wg *sync.WaitGroup, errorCo
 				}
 				continue
 			}
+			stats.recordSuccess(query, elapsed, numRows)
 			log.Printf("[%d] finished query %d: %d rows returned after %4.2f seconds\n",
 				id, query, numRows, elapsed.Seconds())
 		}
@@ -159,22 +232,48 @@ func main() {
 		fmt.Printf("Setting up database connection failed: %s, continuing assuming database already exists.", err)
 	}
 
-	if (*restore != "") && *load {
+	restoreLoc := *restore
+	if *restoreBase != "" {
+		if *restore != "" {
+			log.Fatal("only one of -restore or -restore-base must be specified.")
+		}
+		if restoreLoc, err = backupLocation(*restoreBase); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if (restoreLoc != "") && *load {
 		log.Fatal("only one of -load or -restore must be specified.")
 	}
 
+	d, ok := dialects[*dialectName]
+	if !ok {
+		log.Fatalf("unknown dialect %q, must be cockroach or postgres", *dialectName)
+	}
+	if (restoreLoc != "" || *backup != "") && !d.supportsBackup {
+		log.Fatalf("-backup and -restore are not supported by the %s dialect", *dialectName)
+	}
+	s, ok := schemas[*schemaName]
+	if !ok {
+		log.Fatalf("unknown schema %q, must be one of default, spec, interleaved or minimal", *schemaName)
+	}
+	if s.interleaved() && !d.supportsInterleave {
+		log.Fatalf("the %s schema is not supported by the %s dialect", *schemaName, *dialectName)
+	}
+
 	// Ensure the database exists
-	if err = crdb.ExecuteTx(db, func(tx *sql.Tx) error {
-		_, inErr := tx.Exec("CREATE DATABASE IF NOT EXISTS tpch")
-		return inErr
-	}); err != nil {
-		if *verbose {
-			log.Fatalf("failed to create database: %s\n", err)
+	if d.createDatabaseStmt != "" {
+		if err = crdb.ExecuteTx(db, func(tx *sql.Tx) error {
+			_, inErr := tx.Exec(d.createDatabaseStmt)
+			return inErr
+		}); err != nil {
+			if *verbose {
+				log.Fatalf("failed to create database: %s\n", err)
+			}
 		}
 	}
 
-	if *restore != "" {
-		if err = runRestore(db, *restore); err != nil {
+	if restoreLoc != "" {
+		if err = runRestore(db, restoreLoc); err != nil {
 			log.Fatalf("restore failed: %s", err)
 		}
 	}
@@ -208,12 +307,68 @@ func main() {
 			log.Fatal("failed to create indexes: ", err)
 		}
 
+		if err := runPostLoad(db); err != nil {
+			log.Fatal("failed to run post-load statements: ", err)
+		}
+
 		if *verbose {
 			log.Printf("loading complete, total time elapsed: %s\n",
 				time.Since(loadStart))
 		}
 	}
 
+	if *verify {
+		if err := verifyDatabase(db); err != nil {
+			log.Fatalf("verification failed: %s", err)
+		}
+		log.Printf("verification passed")
+	}
+
+	if *backup != "" {
+		if err := runBackup(db, *backup); err != nil {
+			log.Fatalf("backup failed: %s", err)
+		}
+		return
+	}
+
+	if *check && *scaleFactor != 1 {
+		log.Fatalf("-check requires -scale-factor=1, the reference answers are only valid at scale factor 1")
+	}
+	if *check {
+		*fixedParams = true
+	}
+	if !*fixedParams {
+		log.Printf("using query parameter seed %d", *seed)
+	}
+
+	// Cancel any running queries and stop gracefully on the first interrupt,
+	// and exit immediately on the second.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan os.Signal, 3)
+	signal.Notify(done, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-done
+		log.Printf("interrupted, cancelling queries")
+		cancel()
+		<-done
+		os.Exit(1)
+	}()
+
+	if *benchmark != "" {
+		var all []int
+		for query := 1; query <= numQueries; query++ {
+			all = append(all, query)
+		}
+		if _, unsupported := probeQueries(db, all); len(unsupported) > 0 && !*allowUnsupported {
+			log.Fatalf("the benchmark requires all %d queries, but queries %v are unsupported "+
+				"(use -allow-unsupported to run them anyway)", numQueries, unsupported)
+		}
+		if err := runBenchmark(ctx, db); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Create *concurrency goroutines, each looping over queries in *queries.
 	listQueries := strings.Split(*queries, ",")
 	var queries []int
@@ -225,11 +380,59 @@ func main() {
 		}
 		queries = append(queries, queryInt)
 	}
+
+	if *explain || *explainAnalyze {
+		changed, err := runExplain(db, queries)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if changed > 0 {
+			log.Fatalf("%d query plans changed", changed)
+		}
+		return
+	}
+
+	queries, _ = probeQueries(db, queries)
+	if len(queries) == 0 {
+		log.Fatal("none of the selected queries are supported (use -allow-unsupported to run them anyway)")
+	}
+
+	// Check the update sets before starting, like the benchmark does, rather
+	// than finding a missing file partway through the run.
+	var refreshSets int
+	if *refreshInterval > 0 && !*generateRefresh {
+		if refreshSets = availableRefreshSets(); refreshSets == 0 {
+			log.Fatal(checkRefreshSets(1))
+		}
+		log.Printf("found %d update sets in %s", refreshSets, *refreshDir)
+	}
+
 	var wg sync.WaitGroup
 	var errorCount uint64
+	stats := make([]workerStats, *concurrency)
 	for i := uint(0); i < *concurrency; i++ {
+		stats[i] = make(workerStats)
 		wg.Add(1)
-		go loopQueries(i, db, queries, &wg, &errorCount)
+		go loopQueries(ctx, i, db, queries, &wg, &errorCount, stats[i])
+	}
+
+	// Run the refresh functions concurrently with the queries until all of
+	// the queries have finished.
+	var refreshWg sync.WaitGroup
+	stopRefresh := make(chan struct{})
+	if *refreshInterval > 0 {
+		refreshWg.Add(1)
+		go loopRefresh(ctx, db, refreshSets, stopRefresh, &refreshWg, &errorCount)
 	}
 	wg.Wait()
+	close(stopRefresh)
+	refreshWg.Wait()
+
+	printSummary(stats, *loops > 1 && *concurrency > 1)
+
+	if *check {
+		if failed := reportChecks(); failed > 0 {
+			log.Fatalf("%d queries did not match the reference answers", failed)
+		}
+	}
 }
@@ -0,0 +1,286 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+//
+// The parameter generator substitutes values into the query templates in
+// queryStmts the same way the TPC-H qgen utility does. Each template refers
+// to its substitution parameters as :1, :2, ... and the values are drawn
+// according to the "Substitution Parameters" section of each query's
+// definition in the TPC-H specification.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var nations = [...]struct {
+	name   string
+	region int
+}{
+	{"ALGERIA", 0},
+	{"ARGENTINA", 1},
+	{"BRAZIL", 1},
+	{"CANADA", 1},
+	{"EGYPT", 4},
+	{"ETHIOPIA", 0},
+	{"FRANCE", 3},
+	{"GERMANY", 3},
+	{"INDIA", 2},
+	{"INDONESIA", 2},
+	{"IRAN", 4},
+	{"IRAQ", 4},
+	{"JAPAN", 2},
+	{"JORDAN", 4},
+	{"KENYA", 0},
+	{"MOROCCO", 0},
+	{"MOZAMBIQUE", 0},
+	{"PERU", 1},
+	{"CHINA", 2},
+	{"ROMANIA", 3},
+	{"SAUDI ARABIA", 4},
+	{"VIETNAM", 2},
+	{"RUSSIA", 3},
+	{"UNITED KINGDOM", 3},
+	{"UNITED STATES", 1},
+}
+
+var regions = [...]string{"AFRICA", "AMERICA", "ASIA", "EUROPE", "MIDDLE EAST"}
+
+var segments = [...]string{"AUTOMOBILE", "BUILDING", "FURNITURE", "MACHINERY", "HOUSEHOLD"}
+
+var shipModes = [...]string{"REG AIR", "AIR", "RAIL", "SHIP", "TRUCK", "MAIL", "FOB"}
+
+var typeSyllables = [...][]string{
+	{"STANDARD", "SMALL", "MEDIUM", "LARGE", "ECONOMY", "PROMO"},
+	{"ANODIZED", "BURNISHED", "PLATED", "POLISHED", "BRUSHED"},
+	{"TIN", "NICKEL", "BRASS", "STEEL", "COPPER"},
+}
+
+var containerSyllables = [...][]string{
+	{"SM", "LG", "MED", "JUMBO", "WRAP"},
+	{"CASE", "BOX", "BAG", "JAR", "PKG", "PACK", "CAN", "DRUM"},
+}
+
+var colors = [...]string{
+	"almond", "antique", "aquamarine", "azure", "beige", "bisque", "black",
+	"blanched", "blue", "blush", "brown", "burlywood", "burnished",
+	"chartreuse", "chiffon", "chocolate", "coral", "cornflower", "cornsilk",
+	"cream", "cyan", "dark", "deep", "dim", "dodger", "drab", "firebrick",
+	"floral", "forest", "frosted", "gainsboro", "ghost", "goldenrod", "green",
+	"grey", "honeydew", "hot", "indian", "ivory", "khaki", "lace", "lavender",
+	"lawn", "lemon", "light", "lime", "linen", "magenta", "maroon", "medium",
+	"metallic", "midnight", "mint", "misty", "moccasin", "navajo", "navy",
+	"olive", "orange", "orchid", "pale", "papaya", "peach", "peru", "pink",
+	"plum", "powder", "puff", "purple", "red", "rose", "rosy", "royal",
+	"saddle", "salmon", "sandy", "seashell", "sienna", "sky", "slate", "smoke",
+	"snow", "spring", "steel", "tan", "thistle", "tomato", "turquoise",
+	"violet", "wheat", "white", "yellow",
+}
+
+var q13Words = [...][]string{
+	{"special", "pending", "unusual", "express"},
+	{"packages", "requests", "accounts", "deposits"},
+}
+
+// validationParams are the substitution parameters used to produce the
+// reference answers in the TPC-H specification. They are used when
+// -fixed-params is set, and by -check.
+var validationParams = [...][]string{
+	1:  {"90"},
+	2:  {"15", "BRASS", "EUROPE"},
+	3:  {"BUILDING", "1995-03-15"},
+	4:  {"1993-07-01"},
+	5:  {"ASIA", "1994-01-01"},
+	6:  {"1994-01-01", "0.06", "24"},
+	7:  {"FRANCE", "GERMANY"},
+	8:  {"BRAZIL", "AMERICA", "ECONOMY ANODIZED STEEL"},
+	9:  {"green"},
+	10: {"1993-10-01"},
+	11: {"GERMANY", "0.0001"},
+	12: {"MAIL", "SHIP", "1994-01-01"},
+	13: {"special", "requests"},
+	14: {"1995-09-01"},
+	15: {"1996-01-01"},
+	16: {"Brand#45", "MEDIUM POLISHED", "49", "14", "23", "45", "19", "3", "36", "9"},
+	17: {"Brand#23", "MED BOX"},
+	18: {"300"},
+	19: {"1", "10", "20", "Brand#12", "Brand#23", "Brand#34"},
+	20: {"forest", "1994-01-01", "CANADA"},
+	21: {"SAUDI ARABIA"},
+	22: {"13", "31", "23", "29", "30", "18", "17"},
+}
+
+// randomParams generate a set of substitution parameters for each query.
+var randomParams = [...]func(r *rand.Rand) []string{
+	1: func(r *rand.Rand) []string {
+		return []string{randInt(r, 60, 120)}
+	},
+	2: func(r *rand.Rand) []string {
+		return []string{randInt(r, 1, 50), randChoice(r, typeSyllables[2]), randChoice(r, regions[:])}
+	},
+	3: func(r *rand.Rand) []string {
+		return []string{randChoice(r, segments[:]), randDay(r, 1995, time.March)}
+	},
+	4: func(r *rand.Rand) []string {
+		return []string{randMonth(r, 1993, time.January, 1997, time.October)}
+	},
+	5: func(r *rand.Rand) []string {
+		return []string{randChoice(r, regions[:]), randYear(r)}
+	},
+	6: func(r *rand.Rand) []string {
+		return []string{randYear(r), fmt.Sprintf("0.%02d", 2+r.Intn(8)), randInt(r, 24, 25)}
+	},
+	7: func(r *rand.Rand) []string {
+		n := r.Perm(len(nations))
+		return []string{nations[n[0]].name, nations[n[1]].name}
+	},
+	8: func(r *rand.Rand) []string {
+		n := nations[r.Intn(len(nations))]
+		return []string{n.name, regions[n.region], randSyllables(r, typeSyllables[:])}
+	},
+	9: func(r *rand.Rand) []string {
+		return []string{randChoice(r, colors[:])}
+	},
+	10: func(r *rand.Rand) []string {
+		return []string{randMonth(r, 1993, time.February, 1995, time.January)}
+	},
+	11: func(r *rand.Rand) []string {
+		fraction := strconv.FormatFloat(0.0001/float64(*scaleFactor), 'f', -1, 64)
+		return []string{randNation(r), fraction}
+	},
+	12: func(r *rand.Rand) []string {
+		m := r.Perm(len(shipModes))
+		return []string{shipModes[m[0]], shipModes[m[1]], randYear(r)}
+	},
+	13: func(r *rand.Rand) []string {
+		return []string{randChoice(r, q13Words[0]), randChoice(r, q13Words[1])}
+	},
+	14: func(r *rand.Rand) []string {
+		return []string{randMonth(r, 1993, time.January, 1997, time.December)}
+	},
+	15: func(r *rand.Rand) []string {
+		return []string{randMonth(r, 1993, time.January, 1997, time.October)}
+	},
+	16: func(r *rand.Rand) []string {
+		params := []string{randBrand(r), randSyllables(r, typeSyllables[:2])}
+		for _, size := range r.Perm(50)[:8] {
+			params = append(params, strconv.Itoa(size+1))
+		}
+		return params
+	},
+	17: func(r *rand.Rand) []string {
+		return []string{randBrand(r), randSyllables(r, containerSyllables[:])}
+	},
+	18: func(r *rand.Rand) []string {
+		return []string{randInt(r, 312, 315)}
+	},
+	19: func(r *rand.Rand) []string {
+		return []string{
+			randInt(r, 1, 10), randInt(r, 10, 20), randInt(r, 20, 30),
+			randBrand(r), randBrand(r), randBrand(r),
+		}
+	},
+	20: func(r *rand.Rand) []string {
+		return []string{randChoice(r, colors[:]), randYear(r), randNation(r)}
+	},
+	21: func(r *rand.Rand) []string {
+		return []string{randNation(r)}
+	},
+	22: func(r *rand.Rand) []string {
+		var params []string
+		for _, n := range r.Perm(len(nations))[:7] {
+			params = append(params, strconv.Itoa(n+10))
+		}
+		return params
+	},
+}
+
+// randInt returns a random integer in [min, max] as a string.
+func randInt(r *rand.Rand, min, max int) string {
+	return strconv.Itoa(min + r.Intn(max-min+1))
+}
+
+func randChoice(r *rand.Rand, choices []string) string {
+	return choices[r.Intn(len(choices))]
+}
+
+func randNation(r *rand.Rand) string {
+	return nations[r.Intn(len(nations))].name
+}
+
+// randSyllables concatenates a random choice from each list of syllables, as
+// used for p_type and p_container.
+func randSyllables(r *rand.Rand, syllables [][]string) string {
+	words := make([]string, len(syllables))
+	for i, s := range syllables {
+		words[i] = randChoice(r, s)
+	}
+	return strings.Join(words, " ")
+}
+
+// randBrand returns a random p_brand of the form Brand#MN.
+func randBrand(r *rand.Rand) string {
+	return fmt.Sprintf("Brand#%d%d", 1+r.Intn(5), 1+r.Intn(5))
+}
+
+// randYear returns January 1st of a random year in [1993, 1997].
+func randYear(r *rand.Rand) string {
+	return formatDate(time.Date(1993+r.Intn(5), time.January, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// randMonth returns the first day of a random month between the given months,
+// inclusive.
+func randMonth(r *rand.Rand, fromYear int, fromMonth time.Month, toYear int, toMonth time.Month) string {
+	from := fromYear*12 + int(fromMonth-1)
+	to := toYear*12 + int(toMonth-1)
+	m := from + r.Intn(to-from+1)
+	return formatDate(time.Date(m/12, time.Month(m%12+1), 1, 0, 0, 0, 0, time.UTC))
+}
+
+// randDay returns a random day in the given month.
+func randDay(r *rand.Rand, year int, month time.Month) string {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	days := first.AddDate(0, 1, 0).Sub(first).Hours() / 24
+	return formatDate(first.AddDate(0, 0, r.Intn(int(days))))
+}
+
+func formatDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// queryParams returns the substitution parameters for the given query. If r
+// is nil or -fixed-params is set, the validation parameters are used.
+func queryParams(query int, r *rand.Rand) []string {
+	if r == nil || *fixedParams {
+		return validationParams[query]
+	}
+	return randomParams[query](r)
+}
+
+// substituteParams replaces the :1, :2, ... placeholders in a query template
+// with the given parameters, and the :s placeholder with the number of the
+// stream running the query. As in qgen, :s names the view created by query
+// 15, so that concurrent streams do not share it.
+func substituteParams(template string, params []string, stream int) string {
+	// Substitute in decreasing order so that :1 does not clobber :10.
+	for i := len(params); i > 0; i-- {
+		template = strings.Replace(template, fmt.Sprintf(":%d", i), params[i-1], -1)
+	}
+	return strings.Replace(template, ":s", strconv.Itoa(stream), -1)
+}
@@ -20,8 +20,16 @@ package main
 import (
 	"database/sql"
 	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
 )
 
+// queryStmts are the query templates. Their substitution parameters are
+// filled in by substituteParams.
 var queryStmts = [...]string{
 	1:  query1,
 	2:  query2,
@@ -47,31 +55,108 @@ var queryStmts = [...]string{
 	22: query22,
 }
 
-func runQuery(db *sql.DB, query int) (int, error) {
-	queryString := "SET DISTSQL = 'off'; "
-	if *distsql {
-		queryString = "SET DISTSQL = 'always'; "
+// queryText returns the SQL for the given query with its parameters
+// substituted, prefixed by the session settings.
+func queryText(query int, params []string, stream int) string {
+	return fmt.Sprintf("%s%s", sessionPrefix(), substituteParams(queryStmts[query], params, stream))
+}
+
+// errQueryTimeout is returned by runQuery when a query is cancelled because
+// it ran for longer than *queryTimeout.
+var errQueryTimeout = errors.New("query timed out")
+
+// runQuery runs the given query for the given stream, drawing its
+// substitution parameters from r.
+// The query is cancelled if ctx is cancelled or if it runs for longer than
+// *queryTimeout, in which case errQueryTimeout is returned.
+func runQuery(ctx context.Context, db *sql.DB, query int, r *rand.Rand, stream int) (int, error) {
+	params := queryParams(query, r)
+	if *verbose {
+		log.Printf("query %d parameters: %s", query, strings.Join(params, ", "))
 	}
-	queryString = fmt.Sprintf("%s%s", queryString, queryStmts[query])
 
-	switch query {
-	case 2, 4, 13, 16, 17, 18, 20, 21, 22:
-		fmt.Println("Warning: query is unsupported")
-	case 5, 6, 10, 12, 14:
-		fmt.Println("Warning: query causes Cockroach to panic (see #13692), not running query.")
-		return 0, nil
-	case 11:
-		fmt.Println("Warning: group with having not supported yet")
+	queryString := queryText(query, params, stream)
+
+	var cancel context.CancelFunc
+	if *queryTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *queryTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
 	}
+	defer cancel()
 
-	rows, err := db.Query(queryString)
+	numRows, err := execQuery(ctx, db, query, queryString)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return numRows, errQueryTimeout
+	}
+	return numRows, err
+}
+
+// execQuery runs the query and reads all of the rows it returns. Cancelling
+// ctx cancels the query on the server.
+func execQuery(ctx context.Context, db *sql.DB, query int, queryString string) (int, error) {
+	rows, err := db.QueryContext(ctx, queryString)
 	if err != nil {
 		return 0, err
 	}
+	if *check {
+		return checkRows(query, rows)
+	}
 	var rowCount int
 	for rows.Next() {
 		rowCount++
 	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return rowCount, err
+	}
+	return rowCount, rows.Close()
+}
 
-	return rowCount, nil
+// checkRows reads all of the rows returned by a query and compares them
+// against the query's reference answer, recording the outcome. Mismatches
+// are reported but are not returned as errors.
+func checkRows(query int, rows *sql.Rows) (int, error) {
+	approx, err := approximateColumns(rows)
+	if err != nil {
+		_ = rows.Close()
+		return 0, err
+	}
+	results, err := scanRows(rows)
+	if err != nil {
+		return len(results), err
+	}
+
+	checkErr := checkAnswer(query, results, approx)
+	if checkErr != nil {
+		log.Printf("query %d does not match the reference answer: %s", query, checkErr)
+	}
+	recordCheck(query, checkErr)
+	return len(results), nil
+}
+
+// scanRows reads all of the rows returned by a query, formatting each value
+// with formatValue, and closes rows.
+func scanRows(rows *sql.Rows) ([][]string, error) {
+	defer func() { _ = rows.Close() }()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var results [][]string
+	vals := make([]interface{}, len(cols))
+	for i := range vals {
+		vals[i] = new(interface{})
+	}
+	for rows.Next() {
+		if err := rows.Scan(vals...); err != nil {
+			return results, err
+		}
+		row := make([]string, len(cols))
+		for i, v := range vals {
+			row[i] = formatValue(*v.(*interface{}))
+		}
+		results = append(results, row)
+	}
+	return results, rows.Err()
 }
@@ -18,8 +18,8 @@ FROM
 WHERE
 	c_custkey = o_custkey
 	AND l_orderkey = o_orderkey
-	AND o_orderDATE >= DATE '1994-12-01'
-	AND o_orderDATE < DATE '1994-12-01' + INTERVAL '3' MONTH
+	AND o_orderDATE >= DATE ':1'
+	AND o_orderDATE < DATE ':1' + INTERVAL '3' MONTH
 	AND l_returnflag = 'R'
 	AND c_nationkey = n_nationkey
 GROUP BY
@@ -31,5 +31,6 @@ GROUP BY
 	c_address,
 	c_comment
 ORDER BY
-	revenue DESC;
+	revenue DESC
+LIMIT 20;
 `
@@ -11,12 +11,12 @@ FROM
 WHERE
 	ps_suppkey = s_suppkey
 	AND s_nationkey = n_nationkey
-	AND n_name = 'ETHIOPIA'
+	AND n_name = ':1'
 GROUP BY
 	ps_partkey HAVING
 		SUM(ps_supplycost * ps_availqty) > (
 			SELECT
-				SUM(ps_supplycost * ps_availqty) * 0.0000003333
+				SUM(ps_supplycost * ps_availqty) * :2
 			FROM
 				partsupp,
 				supplier,
@@ -24,7 +24,7 @@ GROUP BY
 			WHERE
 				ps_suppkey = s_suppkey
 				AND s_nationkey = n_nationkey
-				AND n_name = 'ETHIOPIA'
+				AND n_name = ':1'
 		)
 ORDER BY
 	value DESC;
@@ -20,11 +20,11 @@ FROM
 	lineitem
 WHERE
 	o_orderkey = l_orderkey
-	AND l_shipmode IN ('AIR', 'REG AIR')
+	AND l_shipmode IN (':1', ':2')
 	AND l_commitdate < l_receiptdate
 	AND l_shipdate < l_commitdate
-	AND l_receiptdate >= DATE '1997-01-01'
-	AND l_receiptdate < DATE '1997-01-01' + INTERVAL '1' YEAR
+	AND l_receiptdate >= DATE ':3'
+	AND l_receiptdate < DATE ':3' + INTERVAL '1' YEAR
 GROUP BY
 	l_shipmode
 ORDER BY
@@ -12,7 +12,7 @@ FROM
 		FROM
 			customer LEFT OUTER JOIN orders ON
 				c_custkey = o_custkey
-				AND o_comment NOT LIKE '%special%deposits%'
+				AND o_comment NOT LIKE '%:1%:2%'
 		GROUP BY
 			c_custkey
 	) AS c_orders
@@ -11,9 +11,9 @@ FROM
 	part
 WHERE
 	p_partkey = ps_partkey
-	AND p_brand <> 'Brand#41'
-	AND p_type NOT LIKE 'ECONOMY BURNISHED%'
-	AND p_size IN (22, 33, 42, 5, 27, 49, 4, 18)
+	AND p_brand <> ':1'
+	AND p_type NOT LIKE ':2%'
+	AND p_size IN (:3, :4, :5, :6, :7, :8, :9, :10)
 	AND ps_suppkey NOT IN (
 		SELECT
 			s_suppkey
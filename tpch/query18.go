@@ -20,7 +20,7 @@ WHERE
 			lineitem
 		GROUP BY
 			l_orderkey HAVING
-				SUM(l_quantity) > 314
+				SUM(l_quantity) > :1
 	)
 	AND c_custkey = o_custkey
 	AND o_orderkey = l_orderkey
@@ -32,5 +32,6 @@ GROUP BY
 	o_totalprice
 ORDER BY
 	o_totalprice DESC,
-	o_orderdate;
+	o_orderdate
+LIMIT 100;
 `
@@ -9,9 +9,9 @@ FROM
 WHERE
 	(
 		p_partkey = l_partkey
-		AND p_brand = 'Brand#34'
+		AND p_brand = ':4'
 		AND p_container IN ('SM CASE', 'SM BOX', 'SM PACK', 'SM PKG')
-		AND l_quantity >= 5 AND l_quantity <= 5 + 10
+		AND l_quantity >= :1 AND l_quantity <= :1 + 10
 		AND p_size BETWEEN 1 AND 5
 		AND l_shipmode IN ('AIR', 'AIR REG')
 		AND l_shipinstruct = 'DELIVER IN PERSON'
@@ -19,9 +19,9 @@ WHERE
 	OR
 	(
 		p_partkey = l_partkey
-		AND p_brand = 'Brand#51'
+		AND p_brand = ':5'
 		AND p_container IN ('MED BAG', 'MED BOX', 'MED PKG', 'MED PACK')
-		AND l_quantity >= 12 AND l_quantity <= 12 + 10
+		AND l_quantity >= :2 AND l_quantity <= :2 + 10
 		AND p_size BETWEEN 1 AND 10
 		AND l_shipmode IN ('AIR', 'AIR REG')
 		AND l_shipinstruct = 'DELIVER IN PERSON'
@@ -29,9 +29,9 @@ WHERE
 	OR
 	(
 		p_partkey = l_partkey
-		AND p_brand = 'Brand#35'
+		AND p_brand = ':6'
 		AND p_container IN ('LG CASE', 'LG BOX', 'LG PACK', 'LG PKG')
-		AND l_quantity >= 30 AND l_quantity <= 30 + 10
+		AND l_quantity >= :3 AND l_quantity <= :3 + 10
 		AND p_size BETWEEN 1 AND 15
 		AND l_shipmode IN ('AIR', 'AIR REG')
 		AND l_shipinstruct = 'DELIVER IN PERSON'
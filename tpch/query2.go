@@ -19,11 +19,11 @@ FROM
 WHERE
 	p_partkey = ps_partkey
 	AND s_suppkey = ps_suppkey
-	AND p_size = 42
-	AND p_type LIKE '%STEEL'
+	AND p_size = :1
+	AND p_type LIKE '%:2'
 	AND s_nationkey = n_nationkey
 	AND n_regionkey = r_regionkey
-	AND r_name = 'AMERICA'
+	AND r_name = ':3'
 	AND ps_supplycost = (
 		SELECT
 			min(ps_supplycost)
@@ -37,11 +37,12 @@ WHERE
 			AND s_suppkey = ps_suppkey
 			AND s_nationkey = n_nationkey
 			AND n_regionkey = r_regionkey
-			AND r_name = 'AMERICA'
+			AND r_name = ':3'
 	)
 ORDER BY
 	s_acctbal DESC,
 	n_name,
 	s_name,
-	p_partkey;
+	p_partkey
+LIMIT 100;
 `
@@ -34,10 +34,11 @@ WHERE
 			AND l3.l_receiptDATE > l3.l_commitdate
 	)
 	AND s_nationkey = n_nationkey
-	AND n_name = 'SAUDI ARABIA'
+	AND n_name = ':1'
 GROUP BY
 	s_name
 ORDER BY
 	numwait DESC,
-	s_name;
+	s_name
+LIMIT 100;
 `
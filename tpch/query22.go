@@ -14,7 +14,7 @@ FROM
 			customer
 		WHERE
 			substring(c_phone FROM 1 FOR 2) in
-				('20', '32', '44', '33', '29', '22', '31')
+				(':1', ':2', ':3', ':4', ':5', ':6', ':7')
 			AND c_acctbal > (
 				SELECT
 					AVG(c_acctbal)
@@ -23,7 +23,7 @@ FROM
 				WHERE
 					c_acctbal > 0.00
 					AND substring(c_phone FROM 1 FOR 2) in
-						('20', '32', '44', '33', '29', '22', '31')
+						(':1', ':2', ':3', ':4', ':5', ':6', ':7')
 			)
 			AND NOT EXISTS (
 				SELECT
@@ -11,16 +11,17 @@ FROM
 	orders,
 	lineitem
 WHERE
-	c_mktsegment = 'MACHINERY'
+	c_mktsegment = ':1'
 	AND c_custkey = o_custkey
 	AND l_orderkey = o_orderkey
-	AND o_orderDATE < DATE '1995-03-10'
-	AND l_shipdate > DATE '1995-03-10'
+	AND o_orderDATE < DATE ':2'
+	AND l_shipdate > DATE ':2'
 GROUP BY
 	l_orderkey,
 	o_orderdate,
 	o_shippriority
 ORDER BY
 	revenue DESC,
-	o_orderdate;
+	o_orderdate
+LIMIT 10;
 `
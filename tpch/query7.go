@@ -27,8 +27,8 @@ FROM
 			AND s_nationkey = n1.n_nationkey
 			AND c_nationkey = n2.n_nationkey
 			AND (
-				(n1.n_name = 'MOZAMBIQUE' AND n2.n_name = 'CANADA')
-				or (n1.n_name = 'CANADA' AND n2.n_name = 'MOZAMBIQUE')
+				(n1.n_name = ':1' AND n2.n_name = ':2')
+				or (n1.n_name = ':2' AND n2.n_name = ':1')
 			)
 			AND l_shipdate BETWEEN DATE '1995-01-01' AND DATE '1996-12-31'
 	) AS shipping
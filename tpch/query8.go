@@ -4,7 +4,7 @@ var query8 = `
 SELECT
 	o_year,
 	SUM(CASE
-		WHEN nation = 'CANADA' THEN volume
+		WHEN nation = ':1' THEN volume
 		ELSE 0
 	END) / SUM(volume) AS mkt_share
 FROM
@@ -29,10 +29,10 @@ FROM
 			AND o_custkey = c_custkey
 			AND c_nationkey = n1.n_nationkey
 			AND n1.n_regionkey = r_regionkey
-			AND r_name = 'AMERICA'
+			AND r_name = ':2'
 			AND s_nationkey = n2.n_nationkey
 			AND o_orderdate BETWEEN DATE '1995-01-01' AND DATE '1996-12-31'
-			AND p_type = 'ECONOMY POLISHED STEEL'
+			AND p_type = ':3'
 	) AS all_nations
 GROUP BY
 	o_year
@@ -0,0 +1,283 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+//
+// The refresh functions implement RF1 (new sales) and RF2 (old sales) from
+// the TPC-H specification, using the update sets generated by `dbgen -U`.
+// Update set n consists of the files orders.tbl.u<n>, lineitem.tbl.u<n> and
+// delete.<n>. If -generate-refresh is set, the refresh data is generated
+// in-process instead and the update set is ignored.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/cockroach-go/crdb"
+	"github.com/pkg/errors"
+)
+
+// runRefresh1 inserts the new orders and lineitems from the given update set.
+// Each order is inserted in the same transaction as its lineitems. Unlike the
+// initial load, -insert-limit does not apply.
+func runRefresh1(db *sql.DB, set int) error {
+	if *generateRefresh {
+		return generateRefresh1(db)
+	}
+	if *verbose {
+		fmt.Printf("Running RF1 with update set %d\n", set)
+	}
+	orderFields, err := readUpdateFile(fmt.Sprintf("orders.tbl.u%d", set))
+	if err != nil {
+		return err
+	}
+	lineitemFields, err := readUpdateFile(fmt.Sprintf("lineitem.tbl.u%d", set))
+	if err != nil {
+		return err
+	}
+	_, ordersValues, err := insertStatement(orders)
+	if err != nil {
+		return err
+	}
+	_, lineitemValues, err := insertStatement(lineitem)
+	if err != nil {
+		return err
+	}
+
+	// Both o_orderkey and l_orderkey are the first field.
+	lineitemsByOrder := make(map[string][]string)
+	for _, fields := range lineitemFields {
+		key := fields[0].(string)
+		lineitemsByOrder[key] = append(lineitemsByOrder[key], fmt.Sprintf(lineitemValues, fields...))
+	}
+
+	var orderRows, lineitemRows []string
+	for _, fields := range orderFields {
+		orderRows = append(orderRows, fmt.Sprintf(ordersValues, fields...))
+		lineitemRows = append(lineitemRows, lineitemsByOrder[fields[0].(string)]...)
+		if uint(len(orderRows)) == *insertsPerTransaction {
+			if err := insertOrders(db, orderRows, lineitemRows); err != nil {
+				return errors.Wrap(err, "RF1 failed")
+			}
+			orderRows, lineitemRows = orderRows[:0], lineitemRows[:0]
+		}
+	}
+	if len(orderRows) > 0 {
+		if err := insertOrders(db, orderRows, lineitemRows); err != nil {
+			return errors.Wrap(err, "RF1 failed")
+		}
+	}
+	return nil
+}
+
+// readUpdateFile returns the fields of each row of the given file in
+// *refreshDir.
+func readUpdateFile(name string) ([][]interface{}, error) {
+	filename := filepath.Join(*refreshDir, name)
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			fmt.Printf("Error encountered when closing file '%s'\n: %s", filename, err)
+		}
+	}()
+
+	var rows [][]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		splits := strings.Split(scanner.Text(), "|")
+		// Ignore the last index since dbgen uses '|' as a terminator, not a separator.
+		fields := make([]interface{}, len(splits)-1)
+		for i := range fields {
+			fields[i] = splits[i]
+		}
+		rows = append(rows, fields)
+	}
+	return rows, scanner.Err()
+}
+
+// insertOrders inserts the given orders and their lineitems, formatted as
+// rows of VALUES, in a single transaction.
+func insertOrders(db *sql.DB, orderRows, lineitemRows []string) error {
+	ordersPreamble, _, err := insertStatement(orders)
+	if err != nil {
+		return err
+	}
+	lineitemPreamble, _, err := insertStatement(lineitem)
+	if err != nil {
+		return err
+	}
+	return crdb.ExecuteTx(db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(ordersPreamble + strings.Join(orderRows, ", ")); err != nil {
+			return err
+		}
+		if len(lineitemRows) == 0 {
+			return nil
+		}
+		_, err := tx.Exec(lineitemPreamble + strings.Join(lineitemRows, ", "))
+		return err
+	})
+}
+
+// runRefresh2 deletes the old orders, and their lineitems, listed in the
+// given update set.
+func runRefresh2(db *sql.DB, set int) error {
+	if *generateRefresh {
+		return generateRefresh2(db)
+	}
+	if *verbose {
+		fmt.Printf("Running RF2 with update set %d\n", set)
+	}
+	filename := filepath.Join(*refreshDir, fmt.Sprintf("delete.%d", set))
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			fmt.Printf("Error encountered when closing file '%s'\n: %s", filename, err)
+		}
+	}()
+
+	scanner := bufio.NewScanner(file)
+	keys := make([]string, 0, *insertsPerTransaction)
+	for scanner.Scan() {
+		// Ignore the trailing '|' terminator.
+		keys = append(keys, strings.Split(scanner.Text(), "|")[0])
+		if uint(len(keys)) == *insertsPerTransaction {
+			if err := deleteOrders(db, keys); err != nil {
+				return errors.Wrap(err, "RF2 failed")
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		if err := deleteOrders(db, keys); err != nil {
+			return errors.Wrap(err, "RF2 failed")
+		}
+	}
+	return nil
+}
+
+// deleteOrders deletes the given orders and their lineitems in a single
+// transaction.
+func deleteOrders(db *sql.DB, keys []string) error {
+	keyList := strings.Join(keys, ", ")
+	return crdb.ExecuteTx(db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(fmt.Sprintf(
+			"DELETE FROM lineitem WHERE l_orderkey IN (%s)", keyList)); err != nil {
+			return err
+		}
+		_, err := tx.Exec(fmt.Sprintf("DELETE FROM orders WHERE o_orderkey IN (%s)", keyList))
+		return err
+	})
+}
+
+// checkRefreshSets returns an error if any of the files for update sets 1
+// through n are missing.
+func checkRefreshSets(n int) error {
+	if *generateRefresh {
+		return nil
+	}
+	for set := 1; set <= n; set++ {
+		if err := checkRefreshSet(set); err != nil {
+			return errors.Wrapf(err, "missing refresh data for update set %d "+
+				"(generate it with `dbgen -U %d`)", set, n)
+		}
+	}
+	return nil
+}
+
+// checkRefreshSet returns an error if any of the files for the given update
+// set are missing.
+func checkRefreshSet(set int) error {
+	for _, name := range []string{
+		fmt.Sprintf("orders.tbl.u%d", set),
+		fmt.Sprintf("lineitem.tbl.u%d", set),
+		fmt.Sprintf("delete.%d", set),
+	} {
+		if _, err := os.Stat(filepath.Join(*refreshDir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// availableRefreshSets returns the number of consecutive update sets,
+// starting from set 1, whose files are all present.
+func availableRefreshSets() int {
+	n := 0
+	for checkRefreshSet(n+1) == nil {
+		n++
+	}
+	return n
+}
+
+// loopRefresh runs a pair of refresh functions every *refreshInterval until
+// stop is closed or ctx is cancelled, using consecutive update sets. If sets
+// is non-zero, it stops after that many update sets, which have been checked
+// beforehand. Refresh errors are atomically added to errorCount like query
+// errors in loopQueries.
+func loopRefresh(
+	ctx context.Context,
+	db *sql.DB,
+	sets int,
+	stop <-chan struct{},
+	wg *sync.WaitGroup,
+	errorCount *uint64,
+) {
+	defer wg.Done()
+	ticker := time.NewTicker(*refreshInterval)
+	defer ticker.Stop()
+	for set := 1; ; set++ {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if sets > 0 && set > sets {
+			log.Printf("[refresh] all %d update sets have been run, no more refreshes will run", sets)
+			return
+		}
+		if _, _, err := runRefreshPair(db, set); err != nil {
+			newErrorCount := atomic.AddUint64(errorCount, 1)
+			wrappedErr := errors.Wrapf(err, "[refresh] error running update set %d", set)
+			if newErrorCount < *maxErrors || *maxErrors == 0 {
+				log.Print(wrappedErr)
+			} else {
+				log.Fatal(wrappedErr)
+			}
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+//
+// The refresh data generator produces the rows for RF1 in-process, following
+// the data generation rules in section 4.2.3 of the TPC-H specification, so
+// that the refresh functions can run without dbgen update files.
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// Dates from clause 4.2.2.12.
+	startDate   = time.Date(1992, time.January, 1, 0, 0, 0, 0, time.UTC)
+	currentDate = time.Date(1995, time.June, 17, 0, 0, 0, 0, time.UTC)
+	endDate     = time.Date(1998, time.December, 31, 0, 0, 0, 0, time.UTC)
+)
+
+var orderPriorities = [...]string{"1-URGENT", "2-HIGH", "3-MEDIUM", "4-NOT SPECIFIED", "5-LOW"}
+
+var shipInstructions = [...]string{"DELIVER IN PERSON", "COLLECT COD", "NONE", "TAKE BACK RETURN"}
+
+// refreshGen holds the state of the in-process refresh data generator. New
+// orders are given keys above the largest key present when the generator
+// was first used.
+var refreshGen struct {
+	sync.Mutex
+	r            *rand.Rand
+	nextOrderKey int64
+}
+
+// refreshOrderCount returns the number of orders inserted by RF1 and deleted
+// by RF2, which is 0.1% of the initial orders.
+func refreshOrderCount() int {
+	return int(*scaleFactor) * 1500
+}
+
+// generateRefresh1 inserts newly generated orders and lineitems.
+func generateRefresh1(db *sql.DB) error {
+	refreshGen.Lock()
+	defer refreshGen.Unlock()
+	if refreshGen.r == nil {
+		refreshGen.r = rand.New(rand.NewSource(*seed))
+		var maxKey sql.NullInt64
+		if err := db.QueryRow("SELECT MAX(o_orderkey) FROM orders").Scan(&maxKey); err != nil {
+			return err
+		}
+		refreshGen.nextOrderKey = maxKey.Int64 + 1
+	}
+
+	_, ordersValues, err := insertStatement(orders)
+	if err != nil {
+		return err
+	}
+	_, lineitemValues, err := insertStatement(lineitem)
+	if err != nil {
+		return err
+	}
+
+	remaining := refreshOrderCount()
+	for remaining > 0 {
+		n := int(*insertsPerTransaction)
+		if n > remaining {
+			n = remaining
+		}
+		var orderRows, lineitemRows []string
+		for i := 0; i < n; i++ {
+			order, lines := generateOrder(refreshGen.r, refreshGen.nextOrderKey)
+			refreshGen.nextOrderKey++
+			orderRows = append(orderRows, fmt.Sprintf(ordersValues, order...))
+			for _, line := range lines {
+				lineitemRows = append(lineitemRows, fmt.Sprintf(lineitemValues, line...))
+			}
+		}
+		if err := insertOrders(db, orderRows, lineitemRows); err != nil {
+			return errors.Wrap(err, "RF1 failed")
+		}
+		remaining -= n
+	}
+	return nil
+}
+
+// generateRefresh2 deletes the oldest orders, and their lineitems.
+func generateRefresh2(db *sql.DB) error {
+	rows, err := db.Query("SELECT o_orderkey FROM orders ORDER BY o_orderkey LIMIT $1",
+		refreshOrderCount())
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for len(keys) > 0 {
+		n := int(*insertsPerTransaction)
+		if n > len(keys) {
+			n = len(keys)
+		}
+		if err := deleteOrders(db, keys[:n]); err != nil {
+			return errors.Wrap(err, "RF2 failed")
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
+
+// generateOrder generates the fields of an order and its lineitems, in the
+// column order used by insertStatement.
+func generateOrder(r *rand.Rand, orderKey int64) ([]interface{}, [][]interface{}) {
+	sf := int(*scaleFactor)
+	// Customers whose key is a multiple of 3 never place orders.
+	custKey := 1 + r.Intn(sf*150000)
+	for custKey%3 == 0 {
+		custKey = 1 + r.Intn(sf*150000)
+	}
+	orderDate := startDate.AddDate(0, 0, r.Intn(int(endDate.Sub(startDate).Hours()/24)-151+1))
+
+	numLines := 1 + r.Intn(7)
+	lines := make([][]interface{}, numLines)
+	var totalPrice float64
+	var numShipped int
+	for i := range lines {
+		partKey := 1 + r.Intn(sf*200000)
+		numSuppliers := sf * 10000
+		suppKey := (partKey+(r.Intn(4)*(numSuppliers/4+(partKey-1)/numSuppliers)))%numSuppliers + 1
+		quantity := 1 + r.Intn(50)
+		retailPrice := float64(90000+((partKey/10)%20001)+100*(partKey%1000)) / 100
+		extendedPrice := float64(quantity) * retailPrice
+		discount := float64(r.Intn(11)) / 100
+		tax := float64(r.Intn(9)) / 100
+		shipDate := orderDate.AddDate(0, 0, 1+r.Intn(121))
+		commitDate := orderDate.AddDate(0, 0, 30+r.Intn(61))
+		receiptDate := shipDate.AddDate(0, 0, 1+r.Intn(30))
+
+		returnFlag := "N"
+		if !receiptDate.After(currentDate) {
+			returnFlag = "R"
+			if r.Intn(2) == 0 {
+				returnFlag = "A"
+			}
+		}
+		lineStatus := "O"
+		if !shipDate.After(currentDate) {
+			lineStatus = "F"
+			numShipped++
+		}
+		totalPrice += extendedPrice * (1 + tax) * (1 - discount)
+
+		lines[i] = []interface{}{
+			strconv.FormatInt(orderKey, 10),
+			strconv.Itoa(partKey),
+			strconv.Itoa(suppKey),
+			strconv.Itoa(i + 1),
+			strconv.Itoa(quantity),
+			strconv.FormatFloat(extendedPrice, 'f', 2, 64),
+			strconv.FormatFloat(discount, 'f', 2, 64),
+			strconv.FormatFloat(tax, 'f', 2, 64),
+			returnFlag,
+			lineStatus,
+			formatDate(shipDate),
+			formatDate(commitDate),
+			formatDate(receiptDate),
+			randChoice(r, shipInstructions[:]),
+			randChoice(r, shipModes[:]),
+			randComment(r, 10, 43),
+		}
+	}
+
+	orderStatus := "P"
+	switch numShipped {
+	case 0:
+		orderStatus = "O"
+	case numLines:
+		orderStatus = "F"
+	}
+	order := []interface{}{
+		strconv.FormatInt(orderKey, 10),
+		strconv.Itoa(custKey),
+		orderStatus,
+		strconv.FormatFloat(totalPrice, 'f', 2, 64),
+		formatDate(orderDate),
+		randChoice(r, orderPriorities[:]),
+		fmt.Sprintf("Clerk#%09d", 1+r.Intn(sf*1000)),
+		"0",
+		randComment(r, 19, 78),
+	}
+	return order, lines
+}
+
+// randComment returns a random comment with a length between min and max.
+// The spec draws comments from a text grammar; words from the p_name
+// colors are used here instead, which is good enough for refresh data.
+func randComment(r *rand.Rand, min, max int) string {
+	length := min + r.Intn(max-min+1)
+	var buf []string
+	var n int
+	for n < length {
+		word := randChoice(r, colors[:])
+		buf = append(buf, word)
+		n += len(word) + 1
+	}
+	comment := strings.Join(buf, " ")
+	if len(comment) > length {
+		comment = strings.TrimSpace(comment[:length])
+	}
+	return comment
+}
@@ -0,0 +1,142 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+//
+// The schema variants select the physical design used by -load, so that the
+// effect of primary keys, foreign keys, interleaving and secondary indexes on
+// each query can be measured.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// schema is a physical design for the TPC-H tables.
+type schema struct {
+	// primaryKeys are the primary key columns of each table. Tables without
+	// a primary key use the database's implicit row ID.
+	primaryKeys [numTables]string
+	// interleave is the clause appended to the CREATE TABLE statement of
+	// each table that is interleaved in its parent.
+	interleave [numTables]string
+	// indexStmts create the secondary indexes after the data is loaded.
+	indexStmts []string
+	// constraintStmts are run after the indexes are created. The foreign
+	// keys are added here rather than when creating the tables so that the
+	// data files can be loaded in any order.
+	constraintStmts []string
+}
+
+// specPrimaryKeys are the primary keys from clause 1.4.2.2 of the TPC-H
+// specification.
+var specPrimaryKeys = [numTables]string{
+	nation:   "n_nationkey",
+	region:   "r_regionkey",
+	part:     "p_partkey",
+	supplier: "s_suppkey",
+	partsupp: "ps_partkey, ps_suppkey",
+	customer: "c_custkey",
+	orders:   "o_orderkey",
+	lineitem: "l_orderkey, l_linenumber",
+}
+
+// specIndexStmts are the secondary indexes used with the specification's
+// primary keys. Every foreign key is covered by an index, which Cockroach
+// requires.
+var specIndexStmts = []string{
+	`CREATE INDEX n_rk    ON nation (n_regionkey ASC)`,
+	`CREATE INDEX s_nk    ON supplier (s_nationkey ASC)`,
+	`CREATE INDEX ps_sk   ON partsupp (ps_suppkey ASC)`,
+	`CREATE INDEX c_nk    ON customer (c_nationkey ASC)`,
+	`CREATE INDEX o_ck    ON orders (o_custkey ASC)`,
+	`CREATE INDEX o_od    ON orders (o_orderdate ASC)`,
+	`CREATE INDEX l_pk_sk ON lineitem (l_partkey ASC, l_suppkey ASC)`,
+	`CREATE INDEX l_sk_pk ON lineitem (l_suppkey ASC, l_partkey ASC)`,
+	`CREATE INDEX l_sd    ON lineitem (l_shipdate ASC)`,
+	`CREATE INDEX l_cd    ON lineitem (l_commitdate ASC)`,
+	`CREATE INDEX l_rd    ON lineitem (l_receiptdate ASC)`,
+}
+
+// specForeignKeyStmts are the foreign keys from clause 1.4.2.3 of the TPC-H
+// specification.
+var specForeignKeyStmts = []string{
+	`ALTER TABLE nation ADD CONSTRAINT n_region FOREIGN KEY (n_regionkey) REFERENCES region (r_regionkey)`,
+	`ALTER TABLE supplier ADD CONSTRAINT s_nation FOREIGN KEY (s_nationkey) REFERENCES nation (n_nationkey)`,
+	`ALTER TABLE partsupp ADD CONSTRAINT ps_part FOREIGN KEY (ps_partkey) REFERENCES part (p_partkey)`,
+	`ALTER TABLE partsupp ADD CONSTRAINT ps_supplier FOREIGN KEY (ps_suppkey) REFERENCES supplier (s_suppkey)`,
+	`ALTER TABLE customer ADD CONSTRAINT c_nation FOREIGN KEY (c_nationkey) REFERENCES nation (n_nationkey)`,
+	`ALTER TABLE orders ADD CONSTRAINT o_customer FOREIGN KEY (o_custkey) REFERENCES customer (c_custkey)`,
+	`ALTER TABLE lineitem ADD CONSTRAINT l_order FOREIGN KEY (l_orderkey) REFERENCES orders (o_orderkey)`,
+	`ALTER TABLE lineitem ADD CONSTRAINT l_partsupp FOREIGN KEY (l_partkey, l_suppkey) ` +
+		`REFERENCES partsupp (ps_partkey, ps_suppkey)`,
+}
+
+var schemas = map[string]*schema{
+	// default has no primary keys, and unique secondary indexes in their
+	// place.
+	"default": {
+		indexStmts: createIndexStmts[:],
+	},
+	// spec has the primary and foreign keys from the specification.
+	"spec": {
+		primaryKeys:     specPrimaryKeys,
+		indexStmts:      specIndexStmts,
+		constraintStmts: specForeignKeyStmts,
+	},
+	// interleaved is spec with each order's lineitems stored alongside it.
+	"interleaved": {
+		primaryKeys: specPrimaryKeys,
+		interleave: [numTables]string{
+			lineitem: "INTERLEAVE IN PARENT orders (l_orderkey)",
+		},
+		indexStmts:      specIndexStmts,
+		constraintStmts: specForeignKeyStmts,
+	},
+	// minimal has the specification's primary keys and nothing else.
+	"minimal": {
+		primaryKeys: specPrimaryKeys,
+	},
+}
+
+// getSchema returns the schema selected by -schema. The flag is validated at
+// startup.
+func getSchema() *schema {
+	return schemas[*schemaName]
+}
+
+// interleaved returns true if any table in the schema is interleaved.
+func (s *schema) interleaved() bool {
+	for _, clause := range s.interleave {
+		if clause != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// createTableStmt returns the CREATE TABLE statement for the given table.
+func (s *schema) createTableStmt(t table) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "CREATE TABLE %s (%s", tableNames[t], getDialect().columnDefs(t))
+	if pk := s.primaryKeys[t]; pk != "" {
+		fmt.Fprintf(&buf, ",\n      PRIMARY KEY (%s)", pk)
+	}
+	buf.WriteString("\n    )")
+	if clause := s.interleave[t]; clause != "" {
+		fmt.Fprintf(&buf, " %s", clause)
+	}
+	return buf.String()
+}
@@ -0,0 +1,139 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/codahale/hdrhistogram"
+)
+
+const (
+	minLatency = 1 * time.Millisecond
+	maxLatency = 1 * time.Hour
+)
+
+// queryStats holds the latency histogram and outcome counts for a single
+// query number.
+type queryStats struct {
+	count    uint64
+	errors   uint64
+	timeouts uint64
+	rows     uint64
+	latency  *hdrhistogram.Histogram
+}
+
+func newQueryStats() *queryStats {
+	return &queryStats{
+		latency: hdrhistogram.New(minLatency.Nanoseconds(), maxLatency.Nanoseconds(), 3),
+	}
+}
+
+// workerStats holds the statistics for each query run by a single worker,
+// keyed by query number. It is only accessed by its worker until all workers
+// have finished.
+type workerStats map[int]*queryStats
+
+func (w workerStats) get(query int) *queryStats {
+	s, ok := w[query]
+	if !ok {
+		s = newQueryStats()
+		w[query] = s
+	}
+	return s
+}
+
+// recordSuccess records a successful run of a query.
+func (w workerStats) recordSuccess(query int, elapsed time.Duration, numRows int) {
+	s := w.get(query)
+	s.count++
+	s.rows += uint64(numRows)
+	if elapsed < minLatency {
+		elapsed = minLatency
+	} else if elapsed > maxLatency {
+		elapsed = maxLatency
+	}
+	_ = s.latency.RecordValue(elapsed.Nanoseconds())
+}
+
+// recordError records a failed run of a query.
+func (w workerStats) recordError(query int) {
+	w.get(query).errors++
+}
+
+// recordTimeout records a run of a query that was cancelled because it
+// exceeded *queryTimeout.
+func (w workerStats) recordTimeout(query int) {
+	w.get(query).timeouts++
+}
+
+// merge adds the statistics in other to w.
+func (w workerStats) merge(other workerStats) {
+	for query, o := range other {
+		s := w.get(query)
+		s.count += o.count
+		s.errors += o.errors
+		s.timeouts += o.timeouts
+		s.rows += o.rows
+		s.latency.Merge(o.latency)
+	}
+}
+
+func millis(v int64) float64 {
+	return time.Duration(v).Seconds() * 1000
+}
+
+// print prints a row per query, labelled with the given worker name.
+func (w workerStats) print(worker string) {
+	var queries []int
+	for query := range w {
+		queries = append(queries, query)
+	}
+	sort.Ints(queries)
+	for _, query := range queries {
+		s := w[query]
+		if s.count == 0 {
+			fmt.Printf("%6s %5d %8d %8d %8d %10s %10s %10s %10s %10s %10d\n",
+				worker, query, s.count, s.errors, s.timeouts, "-", "-", "-", "-", "-", s.rows)
+			continue
+		}
+		h := s.latency
+		fmt.Printf("%6s %5d %8d %8d %8d %10.1f %10.1f %10.1f %10.1f %10.1f %10d\n",
+			worker, query, s.count, s.errors, s.timeouts,
+			millis(h.Min()),
+			h.Mean()/float64(time.Millisecond),
+			millis(h.ValueAtQuantile(50)),
+			millis(h.ValueAtQuantile(95)),
+			millis(h.Max()),
+			s.rows)
+	}
+}
+
+// printSummary prints the per-query statistics aggregated across all
+// workers. If perWorker is set, each worker's statistics are printed first.
+func printSummary(stats []workerStats, perWorker bool) {
+	fmt.Println("\nworker_query____count___errors_timeouts____min(ms)___mean(ms)____p50(ms)____p95(ms)____max(ms)_______rows")
+	total := make(workerStats)
+	for i, w := range stats {
+		if perWorker {
+			w.print(fmt.Sprintf("%d", i))
+		}
+		total.merge(w)
+	}
+	total.print("all")
+}
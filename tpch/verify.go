@@ -0,0 +1,171 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// baseRowCounts are the number of rows in each table at scale factor 1, from
+// clause 4.2.5 of the TPC-H specification.
+var baseRowCounts = [numTables]int64{
+	nation:   25,
+	region:   5,
+	part:     200000,
+	supplier: 10000,
+	partsupp: 800000,
+	customer: 150000,
+	orders:   1500000,
+	lineitem: 6001215,
+}
+
+// lineitemTolerance is the allowed relative difference between the number of
+// lineitems and its expected value. Each order has a random number of
+// lineitems, so the count only scales approximately.
+const lineitemTolerance = 0.01
+
+// expectedRowCount returns the number of rows the given table should have at
+// the current scale factor.
+func expectedRowCount(t table) int64 {
+	switch t {
+	case nation, region:
+		return baseRowCounts[t]
+	default:
+		return baseRowCounts[t] * int64(*scaleFactor)
+	}
+}
+
+// verifyRowCounts checks that every table has the expected number of rows
+// for the scale factor, and returns an error listing the tables that do not.
+func verifyRowCounts(db *sql.DB) error {
+	var mismatches []string
+	for t := table(0); t < numTables; t++ {
+		var count int64
+		if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", tableNames[t])).Scan(&count); err != nil {
+			return errors.Wrapf(err, "error counting rows in %s", tableNames[t])
+		}
+		expected := expectedRowCount(t)
+		ok := count == expected
+		if t == lineitem {
+			ok = math.Abs(float64(count-expected)) <= lineitemTolerance*float64(expected)
+		}
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s has %d rows, expected %d", tableNames[t], count, expected))
+			continue
+		}
+		if *verbose {
+			log.Printf("%s has %d rows", tableNames[t], count)
+		}
+	}
+	if len(mismatches) > 0 {
+		return errors.Errorf("row counts do not match scale factor %d: %s",
+			*scaleFactor, strings.Join(mismatches, "; "))
+	}
+	return nil
+}
+
+// integrityChecks are queries that count the rows violating the referential
+// integrity between orders and lineitem, and between partsupp, part and
+// supplier.
+var integrityChecks = []struct {
+	description string
+	query       string
+}{
+	{"lineitems without an order",
+		`SELECT COUNT(*) FROM lineitem LEFT JOIN orders ON l_orderkey = o_orderkey WHERE o_orderkey IS NULL`},
+	{"orders without a lineitem",
+		`SELECT COUNT(*) FROM orders LEFT JOIN lineitem ON o_orderkey = l_orderkey WHERE l_orderkey IS NULL`},
+	{"lineitems without a partsupp",
+		`SELECT COUNT(*) FROM lineitem LEFT JOIN partsupp ON l_partkey = ps_partkey AND l_suppkey = ps_suppkey
+		WHERE ps_partkey IS NULL`},
+	{"partsupps without a part",
+		`SELECT COUNT(*) FROM partsupp LEFT JOIN part ON ps_partkey = p_partkey WHERE p_partkey IS NULL`},
+	{"partsupps without a supplier",
+		`SELECT COUNT(*) FROM partsupp LEFT JOIN supplier ON ps_suppkey = s_suppkey WHERE s_suppkey IS NULL`},
+}
+
+// keyRange is the range of values of a table's key column.
+type keyRange struct {
+	t      table
+	column string
+	min    int64
+	// max returns the largest key at the current scale factor.
+	max func() int64
+}
+
+var keyRanges = []keyRange{
+	{nation, "n_nationkey", 0, func() int64 { return baseRowCounts[nation] - 1 }},
+	{region, "r_regionkey", 0, func() int64 { return baseRowCounts[region] - 1 }},
+	{part, "p_partkey", 1, func() int64 { return expectedRowCount(part) }},
+	{supplier, "s_suppkey", 1, func() int64 { return expectedRowCount(supplier) }},
+	{customer, "c_custkey", 1, func() int64 { return expectedRowCount(customer) }},
+	// Order keys are sparse: only the first 8 of every 32 keys are used.
+	{orders, "o_orderkey", 1, func() int64 { return 4 * expectedRowCount(orders) }},
+}
+
+// verifyDatabase checks that the loaded data is complete: every table has
+// the expected number of rows, there are no dangling references between
+// orders and lineitem or between partsupp, part and supplier, and the keys
+// are unique and within the range dbgen generates. It returns an error
+// listing every problem found.
+func verifyDatabase(db *sql.DB) error {
+	var problems []string
+	if err := verifyRowCounts(db); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	for _, c := range integrityChecks {
+		var count int64
+		if err := db.QueryRow(c.query).Scan(&count); err != nil {
+			return errors.Wrapf(err, "error counting %s", c.description)
+		}
+		if count > 0 {
+			problems = append(problems, fmt.Sprintf("%d %s", count, c.description))
+		}
+	}
+
+	for _, r := range keyRanges {
+		// MIN and MAX are NULL if the table is empty.
+		var min, max sql.NullInt64
+		var distinct, count int64
+		if err := db.QueryRow(fmt.Sprintf("SELECT MIN(%[1]s), MAX(%[1]s), COUNT(DISTINCT %[1]s), COUNT(*) FROM %[2]s",
+			r.column, tableNames[r.t])).Scan(&min, &max, &distinct, &count); err != nil {
+			return errors.Wrapf(err, "error reading the range of %s", r.column)
+		}
+		if !min.Valid || !max.Valid {
+			problems = append(problems, fmt.Sprintf("%s is empty", tableNames[r.t]))
+			continue
+		}
+		if min.Int64 < r.min || max.Int64 > r.max() {
+			problems = append(problems, fmt.Sprintf("%s ranges from %d to %d, expected %d to %d",
+				r.column, min.Int64, max.Int64, r.min, r.max()))
+		}
+		if distinct != count {
+			problems = append(problems, fmt.Sprintf("%s has %d duplicate keys", r.column, count-distinct))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.Errorf("%d problems found:\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
+	return nil
+}
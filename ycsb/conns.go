@@ -0,0 +1,100 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package main
+
+import (
+	"database/sql"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// openDB opens a pool of at most maxConns connections to url.
+func openDB(url string, maxConns int) (*sql.DB, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(maxConns)
+	db.SetMaxIdleConns(maxConns)
+	db.SetConnMaxLifetime(*connLifetime)
+	return db, nil
+}
+
+// sqlConns hands out the connections of the workers, spreading them across
+// the URLs given on the command line according to -url-balance.
+type sqlConns struct {
+	urls []string
+	// pools holds a connection pool per URL, shared by the workers. It is
+	// empty with -conn-per-worker.
+	pools []*sql.DB
+	// next is accessed atomically.
+	next uint32
+	mu   struct {
+		sync.Mutex
+		r *rand.Rand
+	}
+}
+
+func newSQLConns(urls []string) (*sqlConns, error) {
+	c := &sqlConns{urls: urls}
+	c.mu.r = rand.New(rand.NewSource(*seed))
+	if !*connPerWorker {
+		// Allow a maximum of concurrency+1 connections to each URL.
+		for _, u := range urls {
+			db, err := openDB(u, *concurrency+1)
+			if err != nil {
+				c.close()
+				return nil, err
+			}
+			c.pools = append(c.pools, db)
+		}
+	}
+	return c, nil
+}
+
+// pick returns the index of the URL to use for the next connection.
+func (c *sqlConns) pick() int {
+	if *urlBalance == "random" {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.mu.r.Intn(len(c.urls))
+	}
+	return int((atomic.AddUint32(&c.next, 1) - 1) % uint32(len(c.urls)))
+}
+
+// get returns a connection for a worker, which is given back with put.
+func (c *sqlConns) get() (*sql.DB, error) {
+	i := c.pick()
+	if *connPerWorker {
+		return openDB(c.urls[i], 1)
+	}
+	return c.pools[i], nil
+}
+
+func (c *sqlConns) put(db *sql.DB) error {
+	if *connPerWorker {
+		return db.Close()
+	}
+	return nil
+}
+
+// close closes the shared pools.
+func (c *sqlConns) close() {
+	for _, db := range c.pools {
+		_ = db.Close()
+	}
+}
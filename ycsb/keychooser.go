@@ -0,0 +1,321 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package main
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// keyChooser chooses the keys read and written by a workload. Keys are
+// identified by their rank: the nth key inserted has rank zipfIMin+n-1. The
+// worker hashes ranks to the keys stored in the database. keyChoosers are
+// safe for concurrent use.
+type keyChooser interface {
+	// readKey returns the rank of a key to read, using r as the source of
+	// randomness.
+	readKey(r *rand.Rand) uint64
+	// writeKey returns the rank of the next key to insert.
+	writeKey() uint64
+	// inserted records that a key was inserted, making it available to
+	// readKey.
+	inserted() error
+	// keyRange returns the smallest and largest ranks readKey may currently
+	// return.
+	keyRange() (iMin, iMax uint64)
+}
+
+// newKeyChooser returns the keyChooser for the named distribution, over the
+// keys with ranks iMin through iMax.
+func newKeyChooser(distribution string, iMin, iMax uint64) (keyChooser, error) {
+	// Every distribution needs at least one key to read.
+	if iMin > iMax {
+		return nil, errors.Errorf("iMin %d > iMax %d", iMin, iMax)
+	}
+	switch distribution {
+	case "zipfian":
+		z, err := NewZipfGenerator(iMin, iMax, *zipfTheta, *verbose)
+		if err != nil {
+			return nil, err
+		}
+		return zipfianChooser{z}, nil
+	case "scrambled-zipfian":
+		size := iMax + 1 - iMin + *expectedInserts
+		z, err := NewZipfGenerator(0, size-1, *zipfTheta, *verbose)
+		if err != nil {
+			return nil, err
+		}
+		return &scrambledZipfianChooser{
+			insertedKeys: newInsertedKeys(iMin, iMax),
+			z:            z,
+			size:         size,
+		}, nil
+	case "uniform":
+		return &uniformChooser{insertedKeys: newInsertedKeys(iMin, iMax)}, nil
+	case "hotspot":
+		if *hotspotDataFraction < 0 || *hotspotDataFraction > 1 ||
+			*hotspotOpsFraction < 0 || *hotspotOpsFraction > 1 {
+			return nil, errors.Errorf("-hotspot-data-fraction and -hotspot-ops-fraction must be between 0 and 1")
+		}
+		return &hotspotChooser{
+			insertedKeys: newInsertedKeys(iMin, iMax),
+			dataFraction: *hotspotDataFraction,
+			opsFraction:  *hotspotOpsFraction,
+		}, nil
+	case "exponential":
+		if *exponentialPercentile <= 0 || *exponentialPercentile >= 100 || *exponentialFrac <= 0 {
+			return nil, errors.Errorf("-exponential-percentile must be between 0 and 100, " +
+				"and -exponential-frac must be positive")
+		}
+		return &exponentialChooser{
+			insertedKeys: newInsertedKeys(iMin, iMax),
+			percentile:   *exponentialPercentile,
+			frac:         *exponentialFrac,
+		}, nil
+	case "sequential":
+		return &sequentialChooser{insertedKeys: newInsertedKeys(iMin, iMax)}, nil
+	default:
+		return nil, errors.Errorf("unknown request distribution %q", distribution)
+	}
+}
+
+// zipfianChooser reads keys with a Zipf distribution over their ranks, so the
+// first keys inserted are the most popular.
+type zipfianChooser struct {
+	z *ZipfGenerator
+}
+
+func (c zipfianChooser) readKey(r *rand.Rand) uint64 { return c.z.Uint64(r) }
+func (c zipfianChooser) writeKey() uint64            { return c.z.IMaxHead() }
+func (c zipfianChooser) inserted() error             { return c.z.IncrementIMax() }
+func (c zipfianChooser) keyRange() (uint64, uint64)  { return c.z.iMin, c.z.IMax() }
+
+// scrambledZipfianChooser reads keys with a Zipf distribution, but scatters
+// the popular keys across the ranks so that popularity does not depend on
+// when a key was inserted. As in YCSB, the keys are scattered over a fixed
+// number of ranks, size, so that the popular keys stay the same as keys are
+// inserted. Ranks that have not been inserted yet are redrawn.
+type scrambledZipfianChooser struct {
+	insertedKeys
+	z    *ZipfGenerator
+	size uint64
+}
+
+func (c *scrambledZipfianChooser) readKey(r *rand.Rand) uint64 {
+	var buf [8]byte
+	h := fnv.New64a()
+	for {
+		binary.LittleEndian.PutUint64(buf[:], c.z.Uint64(r))
+		h.Reset()
+		_, _ = h.Write(buf[:])
+		key := c.iMin + h.Sum64()%c.size
+		if _, iMax := c.keyRange(); key <= iMax {
+			return key
+		}
+	}
+}
+
+// insertedKeys tracks the ranks of the inserted keys for the keyChoosers that
+// are not backed by a ZipfGenerator, in the same way as ZipfGenerator's
+// IMaxHead and IncrementIMax.
+type insertedKeys struct {
+	// iMaxHead and iMax are accessed atomically. They are the first fields
+	// so that they are 64-bit aligned on 32-bit platforms.
+	iMaxHead uint64
+	iMax     uint64
+	iMin     uint64
+}
+
+func newInsertedKeys(iMin, iMax uint64) insertedKeys {
+	return insertedKeys{iMin: iMin, iMax: iMax}
+}
+
+// count returns the number of keys available to read.
+func (k *insertedKeys) count() uint64 {
+	return atomic.LoadUint64(&k.iMax) + 1 - k.iMin
+}
+
+func (k *insertedKeys) writeKey() uint64 {
+	for {
+		head := atomic.LoadUint64(&k.iMaxHead)
+		iMaxHead := head
+		if iMax := atomic.LoadUint64(&k.iMax); iMaxHead < iMax {
+			iMaxHead = iMax
+		}
+		if atomic.CompareAndSwapUint64(&k.iMaxHead, head, iMaxHead+1) {
+			return iMaxHead
+		}
+	}
+}
+
+func (k *insertedKeys) inserted() error {
+	atomic.AddUint64(&k.iMax, 1)
+	return nil
+}
+
+func (k *insertedKeys) keyRange() (uint64, uint64) {
+	return k.iMin, atomic.LoadUint64(&k.iMax)
+}
+
+// uniformChooser reads every key with equal probability.
+type uniformChooser struct {
+	insertedKeys
+}
+
+func (c *uniformChooser) readKey(r *rand.Rand) uint64 {
+	return c.iMin + uint64(r.Int63n(int64(c.count())))
+}
+
+// hotspotChooser sends opsFraction of the reads to the first dataFraction of
+// the keys, and the rest to the remaining keys. Within each set, keys are
+// read uniformly.
+type hotspotChooser struct {
+	insertedKeys
+	dataFraction, opsFraction float64
+}
+
+func (c *hotspotChooser) readKey(r *rand.Rand) uint64 {
+	n := c.count()
+	hot := uint64(float64(n) * c.dataFraction)
+	if hot == 0 {
+		hot = 1
+	}
+	if r.Float64() < c.opsFraction || hot >= n {
+		return c.iMin + uint64(r.Int63n(int64(hot)))
+	}
+	return c.iMin + hot + uint64(r.Int63n(int64(n-hot)))
+}
+
+// exponentialChooser favors the most recently inserted keys, with the
+// popularity of a key decaying exponentially with the number of keys inserted
+// after it: percentile percent of the reads go to the most recent frac of the
+// keys.
+type exponentialChooser struct {
+	insertedKeys
+	percentile, frac float64
+}
+
+func (c *exponentialChooser) readKey(r *rand.Rand) uint64 {
+	n := c.count()
+	gamma := -math.Log(1-c.percentile/100) / (float64(n) * c.frac)
+	for {
+		// 1-r.Float64() is in (0, 1], so its log is finite.
+		offset := uint64(-math.Log(1-r.Float64()) / gamma)
+		if offset < n {
+			return c.iMin + n - 1 - offset
+		}
+	}
+}
+
+// sequentialChooser reads the keys in order of their rank, starting over once
+// it has read them all.
+type sequentialChooser struct {
+	insertedKeys
+	// next is accessed atomically.
+	next uint64
+}
+
+func (c *sequentialChooser) readKey(r *rand.Rand) uint64 {
+	next := atomic.AddUint64(&c.next, 1) - 1
+	return c.iMin + next%c.count()
+}
+
+// driftingChooser rotates the ranks read by another keyChooser over time, so
+// that its hot keys migrate across the keyspace. The hot keys move steadily
+// by rate ranks per second, and additionally jump by shiftFraction of the
+// keys at each of the shifts, which are measured from start.
+type driftingChooser struct {
+	keyChooser
+	start         *atomicTime
+	rate          float64
+	shifts        []time.Duration
+	shiftFraction float64
+}
+
+// newDriftingChooser returns c with its hot keys drifting according to
+// -hot-key-drift-rate and -hot-key-shifts, or c itself if neither is set.
+func newDriftingChooser(c keyChooser, start *atomicTime) (keyChooser, error) {
+	if *hotKeyDriftRate < 0 {
+		return nil, errors.Errorf("-hot-key-drift-rate must not be negative")
+	}
+	if *hotKeyShiftFraction < 0 || *hotKeyShiftFraction > 1 {
+		return nil, errors.Errorf("-hot-key-shift-fraction must be between 0 and 1")
+	}
+	if *hotKeyDriftRate == 0 && len(hotKeyShifts) == 0 {
+		return c, nil
+	}
+	return &driftingChooser{
+		keyChooser:    c,
+		start:         start,
+		rate:          *hotKeyDriftRate,
+		shifts:        hotKeyShifts,
+		shiftFraction: *hotKeyShiftFraction,
+	}, nil
+}
+
+// offset returns the number of ranks by which the keys have drifted, given
+// that there are n of them.
+func (c *driftingChooser) offset(n uint64) uint64 {
+	elapsed := time.Since(c.start.get())
+	offset := c.rate * elapsed.Seconds()
+	for _, shift := range c.shifts {
+		if elapsed < shift {
+			break
+		}
+		offset += c.shiftFraction * float64(n)
+	}
+	return uint64(math.Mod(offset, float64(n)))
+}
+
+func (c *driftingChooser) readKey(r *rand.Rand) uint64 {
+	rank := c.keyChooser.readKey(r)
+	// The range is loaded after drawing the rank so that it includes it.
+	iMin, iMax := c.keyRange()
+	n := iMax + 1 - iMin
+	return iMin + (rank-iMin+c.offset(n))%n
+}
+
+// durationsFlag accumulates comma separated durations, kept in increasing
+// order.
+type durationsFlag []time.Duration
+
+func (f *durationsFlag) String() string {
+	var parts []string
+	for _, d := range *f {
+		parts = append(parts, d.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *durationsFlag) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		*f = append(*f, d)
+	}
+	sort.Slice(*f, func(i, j int) bool { return (*f)[i] < (*f)[j] })
+	return nil
+}
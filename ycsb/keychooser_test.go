@@ -0,0 +1,173 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+var distributions = []string{
+	"zipfian", "scrambled-zipfian", "uniform", "hotspot", "exponential", "sequential",
+}
+
+func TestKeyChoosers(t *testing.T) {
+	const iMin, iMax = 1, 1000
+	const draws = 100000
+	for _, distribution := range distributions {
+		c, err := newKeyChooser(distribution, iMin, iMax)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := rand.New(rand.NewSource(1))
+
+		// Insert some keys, which must be given consecutive ranks starting
+		// from iMax (the loader inserts ranks iMin through iMax-1) and must
+		// then be readable.
+		const inserts = 100
+		for i := uint64(0); i < inserts; i++ {
+			if key := c.writeKey(); key != iMax+i {
+				t.Fatalf("%s: expected to write key %d, got %d", distribution, iMax+i, key)
+			}
+			if err := c.inserted(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		counts := make(map[uint64]int)
+		for i := 0; i < draws; i++ {
+			key := c.readKey(r)
+			if key < iMin || key > iMax+inserts {
+				t.Fatalf("%s: read key %d outside [%d, %d]", distribution, key, iMin, iMax+inserts)
+			}
+			counts[key]++
+		}
+		if len(counts) < (iMax+inserts)/2 {
+			t.Errorf("%s: only %d distinct keys read", distribution, len(counts))
+		}
+	}
+}
+
+func TestKeyChoosersEmptyRange(t *testing.T) {
+	for _, distribution := range distributions {
+		if _, err := newKeyChooser(distribution, 1, 0); err == nil {
+			t.Errorf("%s: expected an error for an empty key range", distribution)
+		}
+	}
+}
+
+func TestHotspotChooser(t *testing.T) {
+	const draws = 100000
+	c := &hotspotChooser{
+		insertedKeys: newInsertedKeys(1, 1000),
+		dataFraction: 0.1,
+		opsFraction:  0.9,
+	}
+	r := rand.New(rand.NewSource(1))
+	var hot int
+	for i := 0; i < draws; i++ {
+		if c.readKey(r) <= 100 {
+			hot++
+		}
+	}
+	if f := float64(hot) / draws; f < 0.88 || f > 0.92 {
+		t.Fatalf("expected 90%% of reads on the hot set, got %.1f%%", f*100)
+	}
+}
+
+func TestDriftingChooser(t *testing.T) {
+	const draws = 10000
+	// All reads go to the 100 hottest of the 1000 keys, ranks 1 through 100.
+	hot := &hotspotChooser{
+		insertedKeys: newInsertedKeys(1, 1000),
+		dataFraction: 0.1,
+		opsFraction:  1,
+	}
+	testCases := []struct {
+		rate     float64
+		shifts   []time.Duration
+		elapsed  time.Duration
+		min, max uint64
+	}{
+		{0, nil, time.Minute, 1, 100},
+		{0, []time.Duration{30 * time.Second, 2 * time.Minute}, time.Minute, 501, 600},
+		{0, []time.Duration{10 * time.Second, 20 * time.Second}, time.Minute, 1, 100},
+		// The hot keys wrap around the end of the keyspace.
+		{10, nil, 95 * time.Second, 951, 1050},
+	}
+	for i, c := range testCases {
+		var start atomicTime
+		start.set(time.Now().Add(-c.elapsed))
+		d := &driftingChooser{
+			keyChooser:    hot,
+			start:         &start,
+			rate:          c.rate,
+			shifts:        c.shifts,
+			shiftFraction: 0.5,
+		}
+		r := rand.New(rand.NewSource(1))
+		for j := 0; j < draws; j++ {
+			key := d.readKey(r)
+			if key < 1 || key > 1000 {
+				t.Fatalf("%d: read key %d outside [1, 1000]", i, key)
+			}
+			// Allow the keys to drift by a further key while the test runs.
+			if unwrapped := key + 1000; !(key >= c.min && key <= c.max+1) &&
+				!(unwrapped >= c.min && unwrapped <= c.max+1) {
+				t.Fatalf("%d: read key %d outside [%d, %d]", i, key, c.min, c.max)
+			}
+		}
+	}
+}
+
+func TestScrambledZipfianHotKeyStable(t *testing.T) {
+	defer func(v uint64) { *expectedInserts = v }(*expectedInserts)
+	*expectedInserts = 1000
+
+	c, err := newKeyChooser("scrambled-zipfian", 1, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hottest := func() uint64 {
+		const draws = 100000
+		r := rand.New(rand.NewSource(1))
+		counts := make(map[uint64]int)
+		var key uint64
+		for i := 0; i < draws; i++ {
+			k := c.readKey(r)
+			if _, iMax := c.keyRange(); k > iMax {
+				t.Fatalf("read key %d, which has not been inserted", k)
+			}
+			counts[k]++
+			if counts[k] > counts[key] {
+				key = k
+			}
+		}
+		return key
+	}
+
+	before := hottest()
+	for i := 0; i < 500; i++ {
+		c.writeKey()
+		if err := c.inserted(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after := hottest(); after != before {
+		t.Fatalf("expected the hottest key to stay %d after inserts, got %d", before, after)
+	}
+}
@@ -33,6 +33,7 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -54,14 +55,41 @@ const (
 	fieldLength    = 100 // In characters
 )
 
-const (
-	zipfS    = 0.99
-	zipfIMin = 1
-)
+const zipfIMin = 1
 
 var concurrency = flag.Int("concurrency", 2*runtime.NumCPU(),
 	"Number of concurrent workers sending read/write requests.")
 var workload = flag.String("workload", "B", "workload type. Choose from A-F.")
+var zipfTheta = flag.Float64("zipf-theta", 0.99,
+	"Skew of the Zipf distribution of keys. Must be >= 0; larger values are more skewed.")
+var requestDistribution = flag.String("request-distribution", "zipfian",
+	"Distribution of the keys read: zipfian, scrambled-zipfian, uniform, hotspot, exponential or sequential")
+var expectedInserts = flag.Uint64("expected-inserts", 0,
+	"Number of keys expected to be inserted after the initial load. The scrambled-zipfian distribution "+
+		"spreads its popular keys over the initial load plus this many keys, and never reads later keys")
+var hotspotDataFraction = flag.Float64("hotspot-data-fraction", 0.2,
+	"Fraction of the keys in the hot set of the hotspot distribution")
+var hotspotOpsFraction = flag.Float64("hotspot-ops-fraction", 0.8,
+	"Fraction of the reads that go to the hot set of the hotspot distribution")
+var exponentialPercentile = flag.Float64("exponential-percentile", 95,
+	"Percentage of the reads of the exponential distribution that go to the most recent "+
+		"-exponential-frac of the keys")
+var exponentialFrac = flag.Float64("exponential-frac", 0.8571,
+	"Fraction of the keys, counting back from the most recently inserted, that receive "+
+		"-exponential-percentile percent of the reads of the exponential distribution")
+var hotKeyDriftRate = flag.Float64("hot-key-drift-rate", 0,
+	"Number of keys per second by which the keys read move through the keyspace, so that the "+
+		"hot keys migrate. If 0, the hot keys only move at -hot-key-shifts")
+var hotKeyShiftFraction = flag.Float64("hot-key-shift-fraction", 0.5,
+	"Fraction of the keyspace by which the keys read jump at each of -hot-key-shifts")
+var hotKeyShifts durationsFlag
+
+func init() {
+	flag.Var(&hotKeyShifts, "hot-key-shifts",
+		"Comma separated times after the start of the run, such as 1m,5m, at which the keys read "+
+			"jump by -hot-key-shift-fraction of the keyspace")
+}
+
 var tolerateErrors = flag.Bool("tolerate-errors", false,
 	"Keep running on error. (default false)")
 var duration = flag.Duration("duration", 0,
@@ -82,8 +110,37 @@ var strictPostgres = flag.Bool("strict-postgres", false,
 var maxWrites = flag.Uint64("max-writes", 7*24*3600*1500,
 	"Maximum number of writes to perform before halting. This is required for accurately generating keys that are uniformly distributed across the keyspace.")
 
+var seed = flag.Int64("seed", time.Now().UnixNano(),
+	"Seed from which the random number generators of the workers are derived. "+
+		"Runs with the same seed and concurrency are reproducible.")
 var splits = flag.Int("splits", 0, "Number of splits to perform before starting normal operations")
 
+var dbName = flag.String("database", "ycsb",
+	"Name of the database (the keyspace for Cassandra) containing the tables")
+var tableName = flag.String("table", "usertable",
+	"Name of the table (the collection for Mongo). With -tables > 1, this is the prefix of the table names")
+var numTables = flag.Int("tables", 1,
+	"Number of independent tables to run against. Workers are spread evenly across the tables")
+var workloadDefFlags workloadDefsFlag
+
+func init() {
+	flag.Var(&workloadDefFlags, "workload-def",
+		"Run a workload on its own table, as table=<name>,workload=<A-F>,concurrency=<n>,rate-limit=<n>,"+
+			"request-distribution=<name>. "+
+			"May be repeated to run several workloads at once. Overrides -table and -tables")
+}
+
+// Connection flags, used with cockroach.
+var connPerWorker = flag.Bool("conn-per-worker", false,
+	"Give each worker its own connection instead of sharing a pool of connections to each URL")
+var urlBalance = flag.String("url-balance", "round-robin",
+	"How workers are spread across the URLs: round-robin or random")
+var connLifetime = flag.Duration("conn-lifetime", 0,
+	"Maximum lifetime of a connection. If 0, connections are reused forever.")
+var reconnectInterval = flag.Duration("reconnect-interval", 0,
+	"Interval at which each worker replaces its connection with one to a newly chosen URL. "+
+		"Requires -conn-per-worker. If 0, never reconnect.")
+
 // Mongo flags. See https://godoc.org/gopkg.in/mgo.v2#Session.SetSafe for details.
 var mongoWMode = flag.String("mongo-wmode", "", "WMode for mongo session (eg: majority)")
 var mongoJ = flag.Bool("mongo-j", false, "Sync journal before op return")
@@ -97,14 +154,30 @@ var readOnly int32
 type database interface {
 	readRow(key uint64) (bool, error)
 	insertRow(key uint64, fields []string) error
-	clone() database
+	// clone returns a database for use by a single worker, which reads and
+	// writes the given table.
+	clone(table string) database
+}
+
+var identifierRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// tableNames returns the names of the tables to run against.
+func tableNames() []string {
+	if *numTables == 1 {
+		return []string{*tableName}
+	}
+	names := make([]string, *numTables)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s%d", *tableName, i+1)
+	}
+	return names
 }
 
 // ycsbWorker independently issues reads, writes, and scans against the database.
 type ycsbWorker struct {
 	db database
-	// An RNG used to generate random keys
-	zipfR *ZipfGenerator
+	// Chooses the keys to read and write
+	keys keyChooser
 	// An RNG used to generate random strings for the values
 	r             *rand.Rand
 	readFreq      float32
@@ -113,6 +186,8 @@ type ycsbWorker struct {
 	minNanosPerOp time.Duration
 	hashFunc      hash.Hash64
 	hashBuf       [8]byte
+	// The statistics of the worker's workload.
+	stats *[statsLength]uint64
 }
 
 type statistic int
@@ -128,8 +203,6 @@ const (
 	statsLength
 )
 
-var globalStats [statsLength]uint64
-
 type operation int
 
 const (
@@ -138,16 +211,15 @@ const (
 	scanOp
 )
 
-func newYcsbWorker(db database, zipfR *ZipfGenerator, workloadFlag string) *ycsbWorker {
-	source := rand.NewSource(int64(time.Now().UnixNano()))
+func newYcsbWorker(db database, def *workloadDef, seed int64) *ycsbWorker {
 	var readFreq, writeFreq, scanFreq float32
 
 	// TODO(arjun): This could be implemented as a token bucket.
 	var minNanosPerOp time.Duration
-	if *rateLimit != 0 {
-		minNanosPerOp = time.Duration(1000000000 / *rateLimit)
+	if def.rateLimit != 0 {
+		minNanosPerOp = time.Duration(1000000000 / def.rateLimit)
 	}
-	switch workloadFlag {
+	switch def.workload {
 	case "A", "a":
 		readFreq = 0.5
 		writeFreq = 0.5
@@ -169,11 +241,12 @@ func newYcsbWorker(db database, zipfR *ZipfGenerator, workloadFlag string) *ycsb
 	case "F", "f":
 		writeFreq = 1.0
 	}
-	r := rand.New(source)
+	r := rand.New(rand.NewSource(seed))
 	return &ycsbWorker{
 		db:            db,
 		r:             r,
-		zipfR:         zipfR,
+		keys:          def.keys,
+		stats:         &def.stats,
 		readFreq:      readFreq,
 		writeFreq:     writeFreq,
 		scanFreq:      scanFreq,
@@ -194,12 +267,13 @@ func (yw *ycsbWorker) hashKey(key uint64) uint64 {
 	return yw.hashFunc.Sum64() & math.MaxInt64
 }
 
-// Keys are chosen by first drawing from a Zipf distribution and hashing the
-// drawn value, so that not all hot keys are close together.
+// Keys are chosen by first drawing a rank from the workload's keyChooser
+// (by default a Zipf distribution) and hashing the drawn value, so that not
+// all hot keys are close together.
 // See YCSB paper section 5.3 for a complete description of how keys are chosen.
 func (yw *ycsbWorker) nextReadKey() uint64 {
 	var hashedKey uint64
-	key := yw.zipfR.Uint64()
+	key := yw.keys.readKey(yw.r)
 	hashedKey = yw.hashKey(key)
 	if *verbose {
 		fmt.Printf("reader: %d -> %d\n", key, hashedKey)
@@ -208,7 +282,7 @@ func (yw *ycsbWorker) nextReadKey() uint64 {
 }
 
 func (yw *ycsbWorker) nextWriteKey() uint64 {
-	key := yw.zipfR.IMaxHead()
+	key := yw.keys.writeKey()
 	hashedKey := yw.hashKey(key)
 	if *verbose {
 		fmt.Printf("writer: %d -> %d\n", key, hashedKey)
@@ -226,7 +300,7 @@ func (yw *ycsbWorker) runLoader(n uint64, numWorkers int, thisWorkerNum int, wg
 			if *verbose {
 				fmt.Printf("error loading row %d: %s\n", i, err)
 			}
-			atomic.AddUint64(&globalStats[writeErrors], 1)
+			atomic.AddUint64(&yw.stats[writeErrors], 1)
 		} else if *verbose {
 			fmt.Printf("loaded %d -> %d\n", i, hashedKey)
 		}
@@ -243,21 +317,21 @@ func (yw *ycsbWorker) runWorker(errCh chan<- error, wg *sync.WaitGroup) {
 		switch yw.chooseOp() {
 		case readOp:
 			if err := yw.readRow(); err != nil {
-				atomic.AddUint64(&globalStats[readErrors], 1)
+				atomic.AddUint64(&yw.stats[readErrors], 1)
 				errCh <- err
 			}
 		case writeOp:
-			if atomic.LoadUint64(&globalStats[writes]) > *maxWrites {
+			if atomic.LoadUint64(&yw.stats[writes]) > *maxWrites {
 				break
 			}
 			key := yw.nextWriteKey()
 			if err := yw.insertRow(key, true); err != nil {
 				errCh <- err
-				atomic.AddUint64(&globalStats[writeErrors], 1)
+				atomic.AddUint64(&yw.stats[writeErrors], 1)
 			}
 		case scanOp:
 			if err := yw.scanRows(); err != nil {
-				atomic.AddUint64(&globalStats[scanErrors], 1)
+				atomic.AddUint64(&yw.stats[scanErrors], 1)
 				errCh <- err
 			}
 		}
@@ -291,11 +365,11 @@ func (yw *ycsbWorker) insertRow(key uint64, increment bool) error {
 	}
 
 	if increment {
-		if err := yw.zipfR.IncrementIMax(); err != nil {
+		if err := yw.keys.inserted(); err != nil {
 			return err
 		}
 	}
-	atomic.AddUint64(&globalStats[writes], 1)
+	atomic.AddUint64(&yw.stats[writes], 1)
 	return nil
 }
 
@@ -305,15 +379,15 @@ func (yw *ycsbWorker) readRow() error {
 		return err
 	}
 	if !empty {
-		atomic.AddUint64(&globalStats[nonEmptyReads], 1)
+		atomic.AddUint64(&yw.stats[nonEmptyReads], 1)
 		return nil
 	}
-	atomic.AddUint64(&globalStats[emptyReads], 1)
+	atomic.AddUint64(&yw.stats[emptyReads], 1)
 	return nil
 }
 
 func (yw *ycsbWorker) scanRows() error {
-	atomic.AddUint64(&globalStats[scans], 1)
+	atomic.AddUint64(&yw.stats[scans], 1)
 	return errors.Errorf("not implemented yet")
 }
 
@@ -333,11 +407,41 @@ func (yw *ycsbWorker) chooseOp() operation {
 }
 
 type cockroach struct {
-	db *sql.DB
+	conns *sqlConns
+	// conn is the worker's connection. It is opened on first use and, with
+	// -reconnect-interval, replaced by a new one once reconnectAt passes.
+	conn        *sql.DB
+	reconnectAt time.Time
+	// table is the qualified name of the table, e.g. ycsb.usertable.
+	table string
+}
+
+// getConn returns the worker's connection, replacing it first if it is due.
+func (c *cockroach) getConn() (*sql.DB, error) {
+	if c.conn != nil && (*reconnectInterval == 0 || time.Now().Before(c.reconnectAt)) {
+		return c.conn, nil
+	}
+	if c.conn != nil {
+		if err := c.conns.put(c.conn); err != nil {
+			return nil, err
+		}
+		c.conn = nil
+	}
+	conn, err := c.conns.get()
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	c.reconnectAt = time.Now().Add(*reconnectInterval)
+	return conn, nil
 }
 
 func (c *cockroach) readRow(key uint64) (bool, error) {
-	res, err := c.db.Query(fmt.Sprintf("SELECT * FROM ycsb.usertable WHERE ycsb_key=%d", key))
+	db, err := c.getConn()
+	if err != nil {
+		return false, err
+	}
+	res, err := db.Query(fmt.Sprintf("SELECT * FROM %s WHERE ycsb_key=%d", c.table, key))
 	if err != nil {
 		return false, err
 	}
@@ -357,32 +461,40 @@ func (c *cockroach) readRow(key uint64) (bool, error) {
 func (c *cockroach) insertRow(key uint64, fields []string) error {
 	// TODO(arjun): Consider using a prepared statement here.
 	var buf bytes.Buffer
-	buf.WriteString("INSERT INTO ycsb.usertable VALUES (")
+	fmt.Fprintf(&buf, "INSERT INTO %s VALUES (", c.table)
 	fmt.Fprintf(&buf, "%d", key)
 	for _, s := range fields {
 		fmt.Fprintf(&buf, ", '%s'", s)
 	}
 	buf.WriteString(")")
-	_, err := c.db.Exec(buf.String())
+	db, err := c.getConn()
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(buf.String())
 	return err
 }
 
-func (c *cockroach) clone() database {
-	return c
+func (c *cockroach) clone(table string) database {
+	return &cockroach{
+		conns: c.conns,
+		table: fmt.Sprintf("%s.%s", *dbName, table),
+	}
 }
 
-func setupCockroach(parsedURL *url.URL) (database, error) {
-	// Open connection to server and create a database.
-	db, err := sql.Open("postgres", parsedURL.String())
+func setupCockroach(parsedURLs []*url.URL, tables []string) (database, error) {
+	var urls []string
+	for _, u := range parsedURLs {
+		urls = append(urls, u.String())
+	}
+	// Open connection to the first server and create a database.
+	db, err := openDB(urls[0], 1)
 	if err != nil {
 		return nil, err
 	}
+	defer db.Close()
 
-	// Allow a maximum of concurrency+1 connections to the database.
-	db.SetMaxOpenConns(*concurrency + 1)
-	db.SetMaxIdleConns(*concurrency + 1)
-
-	if _, err := db.Exec("CREATE DATABASE IF NOT EXISTS ycsb"); err != nil {
+	if _, err := db.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", *dbName)); err != nil {
 		if *verbose {
 			fmt.Printf("Failed to create the database, attempting to continue... %s\n",
 				err)
@@ -392,7 +504,7 @@ func setupCockroach(parsedURL *url.URL) (database, error) {
 	if *strictPostgres {
 		// Since we use absolute paths (ycsb.usertable), create a Postgres schema
 		// to make the absolute paths work.
-		if _, err := db.Exec("CREATE SCHEMA IF NOT EXISTS ycsb"); err != nil {
+		if _, err := db.Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", *dbName)); err != nil {
 			if *verbose {
 				fmt.Printf("Failed to create schema: %s\n", err)
 			}
@@ -401,21 +513,39 @@ func setupCockroach(parsedURL *url.URL) (database, error) {
 		}
 	}
 
+	for _, table := range tables {
+		if err := setupCockroachTable(db, fmt.Sprintf("%s.%s", *dbName, table)); err != nil {
+			return nil, err
+		}
+	}
+
+	// The workers' connections are only opened once setup has succeeded,
+	// so that they are not leaked when it is retried.
+	conns, err := newSQLConns(urls)
+	if err != nil {
+		return nil, err
+	}
+	return &cockroach{conns: conns}, nil
+}
+
+// setupCockroachTable creates the given table, dropping it first if -drop was
+// specified, and splits it if -splits was specified.
+func setupCockroachTable(db *sql.DB, table string) error {
 	if *drop {
 		if *verbose {
 			fmt.Println("Dropping the table")
 		}
-		if _, err := db.Exec("DROP TABLE IF EXISTS ycsb.usertable"); err != nil {
+		if _, err := db.Exec("DROP TABLE IF EXISTS " + table); err != nil {
 			if *verbose {
 				fmt.Printf("Failed to drop the table: %s\n", err)
 			}
-			return nil, err
+			return err
 		}
 	}
 
-	// Create the initial table for storing blocks.
+	// Create the table for storing blocks.
 	createStmt := `
-CREATE TABLE IF NOT EXISTS ycsb.usertable (
+CREATE TABLE IF NOT EXISTS ` + table + ` (
 	ycsb_key BIGINT PRIMARY KEY NOT NULL,
 	FIELD1 TEXT,
 	FIELD2 TEXT,
@@ -429,26 +559,26 @@ CREATE TABLE IF NOT EXISTS ycsb.usertable (
 	FIELD10 TEXT
 )`
 	if _, err := db.Exec(createStmt); err != nil {
-		return nil, err
+		return err
 	}
 
 	if *splits > 0 {
-		// NB: We only need ycsbWorker.hashKey, so passing nil for the database and
-		// ZipfGenerator is ok.
-		w := newYcsbWorker(nil, nil, *workload)
+		// NB: We only need ycsbWorker.hashKey, so passing nil for the database,
+		// an empty workload and any seed is ok.
+		w := newYcsbWorker(nil, &workloadDef{}, 0)
 		for i := 0; i < *splits; i++ {
 			key := w.hashKey(uint64(i))
-			if _, err := db.Exec(`ALTER TABLE ycsb.usertable SPLIT AT VALUES ($1)`, key); err != nil {
+			if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s SPLIT AT VALUES ($1)`, table), key); err != nil {
 				log.Fatal(err)
 			}
 		}
 
-		if _, err := db.Exec(`ALTER TABLE ycsb.usertable SCATTER`); err != nil {
-			return nil, err
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s SCATTER`, table)); err != nil {
+			return err
 		}
 	}
 
-	return &cockroach{db: db}, nil
+	return nil
 }
 
 type mongoBlock struct {
@@ -478,14 +608,14 @@ func (m *mongo) insertRow(key uint64, fields []string) error {
 	})
 }
 
-func (m *mongo) clone() database {
+func (m *mongo) clone(table string) database {
 	return &mongo{
 		// NB: Whoa!
-		kv: m.kv.Database.Session.Copy().DB(m.kv.Database.Name).C(m.kv.Name),
+		kv: m.kv.Database.Session.Copy().DB(m.kv.Database.Name).C(table),
 	}
 }
 
-func setupMongo(parsedURL *url.URL) (database, error) {
+func setupMongo(parsedURL *url.URL, tables []string) (database, error) {
 	session, err := mgo.Dial(parsedURL.String())
 	if err != nil {
 		panic(err)
@@ -494,24 +624,28 @@ func setupMongo(parsedURL *url.URL) (database, error) {
 	session.SetMode(mgo.Monotonic, true)
 	session.SetSafe(&mgo.Safe{WMode: *mongoWMode, J: *mongoJ})
 
-	kv := session.DB("ycsb").C("kv")
+	db := session.DB(*dbName)
 	if *drop {
-		// Intentionally ignore the error as we can't tell if the collection
-		// doesn't exist.
-		_ = kv.DropCollection()
+		for _, table := range tables {
+			// Intentionally ignore the error as we can't tell if the collection
+			// doesn't exist.
+			_ = db.C(table).DropCollection()
+		}
 	}
-	return &mongo{kv: kv}, nil
+	return &mongo{kv: db.C(tables[0])}, nil
 }
 
 type cassandra struct {
 	session *gocql.Session
+	// table is the qualified name of the table, e.g. ycsb.usertable.
+	table string
 }
 
 func (c *cassandra) readRow(key uint64) (bool, error) {
 	var k uint64
 	var fields [10]string
 	if err := c.session.Query(
-		`SELECT * FROM ycsb.usertable WHERE ycsb_key = ? LIMIT 1`,
+		`SELECT * FROM `+c.table+` WHERE ycsb_key = ? LIMIT 1`,
 		key).Consistency(gocql.One).Scan(&k, &fields[0], &fields[1], &fields[2], &fields[3],
 		&fields[4], &fields[5], &fields[6], &fields[7], &fields[8], &fields[9]); err != nil {
 		if err == gocql.ErrNotFound {
@@ -523,7 +657,7 @@ func (c *cassandra) readRow(key uint64) (bool, error) {
 }
 
 func (c *cassandra) insertRow(key uint64, fields []string) error {
-	const stmt = "INSERT INTO ycsb.usertable " +
+	stmt := "INSERT INTO " + c.table + " " +
 		"(ycsb_key, field1, field2, field3, field4, field5, field6, field7, field8, field9, field10) " +
 		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?); "
 	args := make([]interface{}, len(fields)+1)
@@ -534,11 +668,11 @@ func (c *cassandra) insertRow(key uint64, fields []string) error {
 	return c.session.Query(stmt, args...).Exec()
 }
 
-func (c *cassandra) clone() database {
-	return c
+func (c *cassandra) clone(table string) database {
+	return &cassandra{session: c.session, table: fmt.Sprintf("%s.%s", *dbName, table)}
 }
 
-func setupCassandra(parsedURL *url.URL) (database, error) {
+func setupCassandra(parsedURL *url.URL, tables []string) (database, error) {
 	cluster := gocql.NewCluster(parsedURL.Host)
 	cluster.Consistency = gocql.ParseConsistency(*cassandraConsistency)
 	s, err := cluster.CreateSession()
@@ -547,17 +681,17 @@ func setupCassandra(parsedURL *url.URL) (database, error) {
 	}
 
 	if *drop {
-		_ = s.Query(`DROP KEYSPACE ycsb`).RetryPolicy(nil).Exec()
+		_ = s.Query(`DROP KEYSPACE ` + *dbName).RetryPolicy(nil).Exec()
 	}
 
 	createKeyspace := fmt.Sprintf(`
-CREATE KEYSPACE IF NOT EXISTS ycsb WITH REPLICATION = {
+CREATE KEYSPACE IF NOT EXISTS %s WITH REPLICATION = {
   'class' : 'SimpleStrategy',
   'replication_factor' : %d
-};`, *cassandraReplication)
+};`, *dbName, *cassandraReplication)
 
 	const createTable = `
-CREATE TABLE IF NOT EXISTS ycsb.usertable (
+CREATE TABLE IF NOT EXISTS %s.%s (
   ycsb_key BIGINT,
 	FIELD1 BLOB,
 	FIELD2 BLOB,
@@ -575,29 +709,51 @@ CREATE TABLE IF NOT EXISTS ycsb.usertable (
 	if err := s.Query(createKeyspace).RetryPolicy(nil).Exec(); err != nil {
 		log.Fatal(err)
 	}
-	if err := s.Query(createTable).RetryPolicy(nil).Exec(); err != nil {
-		log.Fatal(err)
+	for _, table := range tables {
+		if err := s.Query(fmt.Sprintf(createTable, *dbName, table)).RetryPolicy(nil).Exec(); err != nil {
+			log.Fatal(err)
+		}
 	}
 	return &cassandra{session: s}, nil
 }
 
 // setupDatabase performs initial setup for the example, creating a database
-// with a single table. If the desired table already exists on the cluster, the
-// existing table will be dropped if the -drop flag was specified.
-func setupDatabase(dbURL string) (database, error) {
-	parsedURL, err := url.Parse(dbURL)
-	if err != nil {
-		return nil, err
+// with the given tables. If the desired tables already exist on the cluster,
+// the existing tables will be dropped if the -drop flag was specified. The
+// workers of SQL databases are spread across all of the URLs, while the other
+// databases only use the first.
+func setupDatabase(dbURLs []string, tables []string) (database, error) {
+	var parsedURLs []*url.URL
+	for _, dbURL := range dbURLs {
+		parsedURL, err := url.Parse(dbURL)
+		if err != nil {
+			return nil, err
+		}
+		parsedURLs = append(parsedURLs, parsedURL)
+	}
+	parsedURL := parsedURLs[0]
+	for _, u := range parsedURLs[1:] {
+		if u.Scheme != parsedURL.Scheme {
+			return nil, errors.Errorf("URLs must all be for the same database: %s and %s",
+				parsedURL.Scheme, u.Scheme)
+		}
+	}
+	if !identifierRE.MatchString(*dbName) {
+		return nil, errors.Errorf("invalid database name %q", *dbName)
+	}
+	for _, table := range tables {
+		if !identifierRE.MatchString(table) {
+			return nil, errors.Errorf("invalid table name %q", table)
+		}
 	}
-	parsedURL.Path = "ycsb"
 
 	switch parsedURL.Scheme {
 	case "postgres", "postgresql":
-		return setupCockroach(parsedURL)
+		return setupCockroach(parsedURLs, tables)
 	case "mongodb":
-		return setupMongo(parsedURL)
+		return setupMongo(parsedURL, tables)
 	case "cassandra":
-		return setupCassandra(parsedURL)
+		return setupCassandra(parsedURL, tables)
 	default:
 		return nil, fmt.Errorf("unsupported database: %s", parsedURL.Scheme)
 	}
@@ -605,17 +761,10 @@ func setupDatabase(dbURL string) (database, error) {
 
 var usage = func() {
 	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
-	fmt.Fprintf(os.Stderr, "  %s <db URL>\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s <db URL> [<db URL>...]\n\n", os.Args[0])
 	flag.PrintDefaults()
 }
 
-func snapshotStats() (s [statsLength]uint64) {
-	for i := 0; i < int(statsLength); i++ {
-		s[i] = atomic.LoadUint64(&globalStats[i])
-	}
-	return s
-}
-
 type atomicTime struct {
 	ptr unsafe.Pointer
 }
@@ -635,9 +784,9 @@ func main() {
 		fmt.Fprintf(os.Stdout, "Starting YCSB load generator\n")
 	}
 
-	dbURL := "postgresql://root@localhost:26257/ycsb?sslmode=disable"
-	if flag.NArg() == 1 {
-		dbURL = flag.Arg(0)
+	dbURLs := []string{"postgresql://root@localhost:26257/ycsb?sslmode=disable"}
+	if flag.NArg() > 0 {
+		dbURLs = flag.Args()
 	}
 
 	if *concurrency < 1 {
@@ -645,7 +794,34 @@ func main() {
 			concurrency)
 	}
 
-	db, err := setupDatabase(dbURL)
+	if *urlBalance != "round-robin" && *urlBalance != "random" {
+		log.Fatalf("Unknown URL balancing %q", *urlBalance)
+	}
+
+	if *reconnectInterval > 0 && !*connPerWorker {
+		log.Fatalf("'reconnect-interval' requires 'conn-per-worker'")
+	}
+
+	if *numTables < 1 || *numTables > *concurrency {
+		log.Fatalf("Value of 'tables' flag (%d) must be between 1 and the concurrency (%d)",
+			*numTables, *concurrency)
+	}
+	defs, err := workloadDefs()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var tables []string
+	var totalConcurrency int
+	for _, d := range defs {
+		tables = append(tables, d.table)
+		totalConcurrency += d.concurrency
+	}
+	// The connection pool is sized for the workers of every workload.
+	*concurrency = totalConcurrency
+
+	log.Printf("using seed %d", *seed)
+
+	db, err := setupDatabase(dbURLs, tables)
 
 	if err != nil {
 		log.Fatalf("Setting up database failed: %s", err)
@@ -656,23 +832,33 @@ func main() {
 
 	lastNow := time.Now()
 	var lastOpsCount uint64
-	var lastStats [statsLength]uint64
+	lastStats := make([][statsLength]uint64, len(defs))
 
-	zipfR, err := NewZipfGenerator(zipfIMin, *initialLoad, zipfS, *verbose)
-	if err != nil {
-		panic(err)
-	}
-
-	workers := make([]*ycsbWorker, *concurrency)
-	for i := range workers {
-		workers[i] = newYcsbWorker(db.clone(), zipfR, *workload)
+	// Each workload has its own table, so its own keyspace and keyChooser.
+	// Every worker's RNG is seeded from -seed and the worker's position, so
+	// that the same workers issue the same keys and values from run to run.
+	// The hot keys drift from the start of the run, after the initial load.
+	var start atomicTime
+	var workers []*ycsbWorker
+	for _, d := range defs {
+		d.keys, err = newKeyChooser(d.distribution, zipfIMin, *initialLoad)
+		if err != nil {
+			log.Fatal(err)
+		}
+		d.keys, err = newDriftingChooser(d.keys, &start)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i := 0; i < d.concurrency; i++ {
+			d.workers = append(d.workers, newYcsbWorker(db.clone(d.table), d, *seed+int64(len(workers)+i)))
+		}
+		workers = append(workers, d.workers...)
 	}
 
 	errCh := make(chan error)
 	tick := time.Tick(1 * time.Second)
 	done := make(chan os.Signal, 3)
 	signal.Notify(done, syscall.SIGINT, syscall.SIGTERM)
-	var start atomicTime
 	var startOpsCount uint64
 	var numErr int
 	start.set(time.Now())
@@ -680,9 +866,11 @@ func main() {
 	go func() {
 		loadStart := time.Now()
 		var wg sync.WaitGroup
-		for i, n := 0, len(workers); i < n; i++ {
-			wg.Add(1)
-			go workers[i].runLoader(*initialLoad, n, i, &wg)
+		for _, d := range defs {
+			for i, n := 0, len(d.workers); i < n; i++ {
+				wg.Add(1)
+				go d.workers[i].runLoader(*initialLoad, n, i, &wg)
+			}
 		}
 		wg.Wait()
 		fmt.Printf("Loading complete: %.1fs\n", time.Since(loadStart).Seconds())
@@ -709,6 +897,13 @@ func main() {
 			}()
 		}
 
+		for _, shift := range hotKeyShifts {
+			shift := shift
+			time.AfterFunc(shift, func() {
+				fmt.Printf("%s: hot keys shifted\n", shift)
+			})
+		}
+
 		if *writeDuration > 0 {
 			go func() {
 				time.Sleep(*writeDuration)
@@ -717,6 +912,9 @@ func main() {
 		}
 	}()
 
+	// With several workloads, each tick prints a block with a line per
+	// workload, labelled with its table.
+	multi := len(defs) > 1
 	for i := 0; ; {
 		select {
 		case err := <-errCh:
@@ -732,35 +930,66 @@ func main() {
 			now := time.Now()
 			elapsed := now.Sub(lastNow)
 
-			stats := snapshotStats()
-			opsCount := stats[writes] + stats[emptyReads] +
-				stats[nonEmptyReads] + stats[scans]
 			if i%20 == 0 {
-				fmt.Printf("elapsed______ops/sec__reads/empty/errors___writes/errors____scans/errors\n")
+				if multi {
+					fmt.Printf("elapsed_________table______ops/sec__reads/empty/errors___writes/errors____scans/errors\n")
+				} else {
+					fmt.Printf("elapsed______ops/sec__reads/empty/errors___writes/errors____scans/errors\n")
+				}
+			}
+			var opsCount uint64
+			for j, d := range defs {
+				stats := d.snapshotStats()
+				last := lastStats[j]
+				ops := stats[writes] + stats[emptyReads] +
+					stats[nonEmptyReads] + stats[scans]
+				lastOps := last[writes] + last[emptyReads] +
+					last[nonEmptyReads] + last[scans]
+				opsCount += ops
+				label := ""
+				if multi {
+					label = fmt.Sprintf(" %13s", d.table)
+				}
+				fmt.Printf("%7s%s %12.1f %19s %15s %15s\n",
+					time.Duration(time.Since(start.get()).Seconds()+0.5)*time.Second,
+					label,
+					float64(ops-lastOps)/elapsed.Seconds(),
+					fmt.Sprintf("%d / %d / %d",
+						stats[nonEmptyReads]-last[nonEmptyReads],
+						stats[emptyReads]-last[emptyReads],
+						stats[readErrors]-last[readErrors]),
+					fmt.Sprintf("%d / %d",
+						stats[writes]-last[writes],
+						stats[writeErrors]-last[writeErrors]),
+					fmt.Sprintf("%d / %d",
+						stats[scans]-last[scans],
+						stats[scanErrors]-last[scanErrors]))
+				lastStats[j] = stats
+			}
+			if multi {
+				fmt.Printf("%7s %13s %12.1f\n", "", "all",
+					float64(opsCount-lastOpsCount)/elapsed.Seconds())
 			}
-			fmt.Printf("%7s %12.1f %19s %15s %15s\n",
-				time.Duration(time.Since(start.get()).Seconds()+0.5)*time.Second,
-				float64(opsCount-lastOpsCount)/elapsed.Seconds(),
-				fmt.Sprintf("%d / %d / %d",
-					stats[nonEmptyReads]-lastStats[nonEmptyReads],
-					stats[emptyReads]-lastStats[emptyReads],
-					stats[readErrors]-lastStats[readErrors]),
-				fmt.Sprintf("%d / %d",
-					stats[writes]-lastStats[writes],
-					stats[writeErrors]-lastStats[writeErrors]),
-				fmt.Sprintf("%d / %d",
-					stats[scans]-lastStats[scans],
-					stats[scanErrors]-lastStats[scanErrors]))
-			lastStats = stats
 			lastOpsCount = opsCount
 			lastNow = now
 			i++
 
 		case <-done:
-			stats := snapshotStats()
-			opsCount := stats[writes] + stats[emptyReads] +
-				stats[nonEmptyReads] + stats[scans] - atomic.LoadUint64(&startOpsCount)
 			elapsed := time.Since(start.get()).Seconds()
+			var opsCount uint64
+			if multi {
+				fmt.Printf("\n_________table__ops/sec(total)\n")
+			}
+			for _, d := range defs {
+				stats := d.snapshotStats()
+				ops := stats[writes] + stats[emptyReads] +
+					stats[nonEmptyReads] + stats[scans]
+				if multi {
+					fmt.Printf("%14s %14.1f\n", d.table, float64(ops)/elapsed)
+				}
+				opsCount += ops
+			}
+			opsCount -= atomic.LoadUint64(&startOpsCount)
 			fmt.Printf("\nelapsed__ops/sec(total)__errors(total)\n")
 			fmt.Printf("%6.1fs %14.1f %14d\n",
 				time.Since(start.get()).Seconds(),
@@ -0,0 +1,146 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// workloadDef is a workload that runs against its own table, with its own op
+// mix, key distribution, workers, rate limit and statistics.
+type workloadDef struct {
+	table        string
+	workload     string
+	concurrency  int
+	rateLimit    uint64
+	distribution string
+	// rateLimitSet is true if rate-limit was given in the -workload-def,
+	// since an explicit rate-limit=0 (unlimited) overrides -rate-limit.
+	rateLimitSet bool
+
+	keys    keyChooser
+	workers []*ycsbWorker
+	stats   [statsLength]uint64
+}
+
+func (d *workloadDef) String() string {
+	return fmt.Sprintf("table=%s,workload=%s,concurrency=%d,rate-limit=%d,request-distribution=%s",
+		d.table, d.workload, d.concurrency, d.rateLimit, d.distribution)
+}
+
+// snapshotStats returns the current values of the workload's statistics.
+func (d *workloadDef) snapshotStats() (s [statsLength]uint64) {
+	for i := 0; i < int(statsLength); i++ {
+		s[i] = atomic.LoadUint64(&d.stats[i])
+	}
+	return s
+}
+
+// workloadDefsFlag accumulates -workload-def flags. Each is a comma separated
+// list of key=value settings, for example
+// "table=orders,workload=A,concurrency=8,rate-limit=100". Settings that are
+// left out default to the values of the corresponding flags.
+type workloadDefsFlag []*workloadDef
+
+func (f *workloadDefsFlag) String() string {
+	var parts []string
+	for _, d := range *f {
+		parts = append(parts, d.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+func (f *workloadDefsFlag) Set(v string) error {
+	d := &workloadDef{}
+	for _, setting := range strings.Split(v, ",") {
+		parts := strings.SplitN(setting, "=", 2)
+		if len(parts) != 2 {
+			return errors.Errorf("workload setting %q must be of the form key=value", setting)
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		var err error
+		switch key {
+		case "table":
+			d.table = value
+		case "workload":
+			d.workload = value
+		case "concurrency":
+			d.concurrency, err = strconv.Atoi(value)
+		case "rate-limit":
+			d.rateLimit, err = strconv.ParseUint(value, 10, 64)
+			d.rateLimitSet = true
+		case "request-distribution":
+			d.distribution = value
+		default:
+			return errors.Errorf("unknown workload setting %q, must be one of "+
+				"table, workload, concurrency, rate-limit or request-distribution", key)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid value for workload setting %q", key)
+		}
+	}
+	if d.table == "" {
+		return errors.Errorf("workload %q must specify a table", v)
+	}
+	*f = append(*f, d)
+	return nil
+}
+
+// workloadDefs returns the workloads to run. If no -workload-def flags were
+// given, a workload is run on each of the -tables tables using -workload,
+// with the -concurrency workers spread evenly across them.
+func workloadDefs() ([]*workloadDef, error) {
+	defs := []*workloadDef(workloadDefFlags)
+	if len(defs) == 0 {
+		tables := tableNames()
+		for i, table := range tables {
+			n := *concurrency / len(tables)
+			if i < *concurrency%len(tables) {
+				n++
+			}
+			defs = append(defs, &workloadDef{table: table, concurrency: n})
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, d := range defs {
+		if seen[d.table] {
+			return nil, errors.Errorf("table %q is used by more than one workload", d.table)
+		}
+		seen[d.table] = true
+		if d.workload == "" {
+			d.workload = *workload
+		}
+		if d.concurrency == 0 {
+			d.concurrency = *concurrency
+		}
+		if !d.rateLimitSet {
+			d.rateLimit = *rateLimit
+		}
+		if d.distribution == "" {
+			d.distribution = *requestDistribution
+		}
+		if d.concurrency < 1 {
+			return nil, errors.Errorf("workload on table %q must have a concurrency of at least 1", d.table)
+		}
+	}
+	return defs, nil
+}
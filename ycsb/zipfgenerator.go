@@ -18,6 +18,10 @@
 // ZipfGenerator implements the Incrementing Zipfian Random Number Generator from
 // [1]: "Quickly Generating Billion-Record Synthetic Databases"
 // by Gray, Sundaresan, Englert, Baclawski, and Weinberger, SIGMOD 1994.
+// For theta >= 1, where the method of [1] does not apply, it uses the
+// rejection-inversion method from [2]: "Rejection-Inversion to Generate
+// Variates from Monotone Discrete Distributions" by Hörmann and Derflinger,
+// ACM TOMACS 1996.
 
 package main
 
@@ -25,8 +29,8 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
-	"sync"
-	"time"
+	"sync/atomic"
+	"unsafe"
 
 	"github.com/pkg/errors"
 )
@@ -36,26 +40,43 @@ import (
 // imax parameter without performing an expensive recomputation of the
 // underlying hidden parameters, which is a pattern used in [1] for efficiently
 // generating large volumes of Zipf-distributed records for synthetic data.
-// Second, rand.Zipf only supports theta <= 1, we suppose all values of theta.
+// Second, rand.Zipf only supports theta > 1, we support all values of theta
+// >= 0. Values below 1 use the method of [1], which requires zeta(iMax,
+// theta), and values of 1 or above use rejection-inversion [2], which needs no
+// zeta and so is cheap to increment.
+//
+// A ZipfGenerator is safe for concurrent use without locking. The parameters
+// that depend on iMax are published atomically as an immutable zipfState, and
+// each caller of Uint64 supplies its own RNG, so concurrent draws share no
+// mutable state.
 type ZipfGenerator struct {
-	// The underlying RNG
-	zipfGenMu ZipfGeneratorMu
+	// iMaxHead is accessed atomically. It is the first field so that it is
+	// 64-bit aligned on 32-bit platforms.
+	iMaxHead uint64
+	// state points to the current zipfState, and is accessed atomically.
+	state unsafe.Pointer
 	// supplied values
 	theta float64
 	iMin  uint64
 	// internally computed values
 	alpha, zeta2 float64
-	verbose      bool
+	// rejectionInversion is set if theta >= 1, in which case hIntegralX1 and
+	// s are the constants of [2] that do not depend on iMax.
+	rejectionInversion bool
+	hIntegralX1, s     float64
+	verbose            bool
 }
 
-// ZipfGeneratorMu holds variables which must be globally synced.
-type ZipfGeneratorMu struct {
-	mu       sync.Mutex
-	r        *rand.Rand
-	iMax     uint64
-	iMaxHead uint64
-	eta      float64
-	zetaN    float64
+// zipfState holds the values which change when iMax is incremented. It is
+// never modified once published; IncrementIMax publishes a new one instead.
+type zipfState struct {
+	iMax uint64
+	// Used by the method of [1].
+	eta   float64
+	zetaN float64
+	// Used by rejection-inversion: H(n + 1/2), where n is the number of
+	// values.
+	hIntegralN float64
 }
 
 // NewZipfGenerator constructs a new ZipfGenerator with the given parameters.
@@ -64,21 +85,23 @@ func NewZipfGenerator(iMin, iMax uint64, theta float64, verbose bool) (*ZipfGene
 	if iMin > iMax {
 		return nil, errors.Errorf("iMin %d > iMax %d", iMin, iMax)
 	}
-	if theta < 0.0 || theta == 1.0 {
-		return nil, errors.Errorf("0 < theta, and theta != 1")
+	if theta < 0.0 {
+		return nil, errors.Errorf("0 <= theta")
 	}
 
 	z := ZipfGenerator{
-		iMin: iMin,
-		zipfGenMu: ZipfGeneratorMu{
-			r:    rand.New(rand.NewSource(int64(time.Now().UnixNano()))),
-			iMax: iMax,
-		},
+		iMin:    iMin,
 		theta:   theta,
 		verbose: verbose,
 	}
-	z.zipfGenMu.mu.Lock()
-	defer z.zipfGenMu.mu.Unlock()
+
+	if theta >= 1.0 {
+		z.rejectionInversion = true
+		z.hIntegralX1 = z.hIntegral(1.5) - 1.0
+		z.s = 2.0 - z.hIntegralInverse(z.hIntegral(2.5)-z.h(2.0))
+		z.state = unsafe.Pointer(z.newState(iMax, 0))
+		return &z, nil
+	}
 
 	// Compute hidden parameters
 	zeta2, err := computeZetaFromScratch(2, theta)
@@ -91,22 +114,46 @@ func NewZipfGenerator(iMin, iMax uint64, theta float64, verbose bool) (*ZipfGene
 		return nil, errors.Errorf("Could not compute zeta(2,%d): %s", iMax, err)
 	}
 	z.alpha = 1.0 / (1.0 - theta)
-	z.zipfGenMu.eta = (1 - math.Pow(2.0/float64(z.zipfGenMu.iMax+1-z.iMin), 1.0-theta)) / (1.0 - zeta2/zetaN)
-	z.zipfGenMu.zetaN = zetaN
 	z.zeta2 = zeta2
+	z.state = unsafe.Pointer(z.newState(iMax, zetaN))
 	return &z, nil
 }
 
+// newState returns the zipfState for the given iMax and zeta(iMax, theta).
+// zetaN is ignored when using rejection-inversion.
+func (z *ZipfGenerator) newState(iMax uint64, zetaN float64) *zipfState {
+	if z.rejectionInversion {
+		return &zipfState{
+			iMax:       iMax,
+			hIntegralN: z.hIntegral(float64(iMax+1-z.iMin) + 0.5),
+		}
+	}
+	return &zipfState{
+		iMax:  iMax,
+		eta:   (1 - math.Pow(2.0/float64(iMax+1-z.iMin), 1.0-z.theta)) / (1.0 - z.zeta2/zetaN),
+		zetaN: zetaN,
+	}
+}
+
+func (z *ZipfGenerator) loadState() *zipfState {
+	return (*zipfState)(atomic.LoadPointer(&z.state))
+}
+
+// IMax returns the current value of iMax.
+func (z *ZipfGenerator) IMax() uint64 {
+	return z.loadState().iMax
+}
+
 // computeZetaIncrementally recomputes zeta(iMax, theta), assuming that
 // sum = zeta(oldIMax, theta). It returns zeta(iMax, theta), computed incrementally.
 func computeZetaIncrementally(oldIMax, iMax uint64, theta float64, sum float64) (float64, error) {
 	if iMax < oldIMax {
 		return 0, errors.Errorf("Can't increment iMax backwards!")
 	}
-	for i := oldIMax + 1; i <= iMax; i++ {
-		sum += 1.0 / math.Pow(float64(i), theta)
+	if iMax == oldIMax {
+		return sum, nil
 	}
-	return sum, nil
+	return sum + zetaTerms(oldIMax+1, iMax, theta), nil
 }
 
 // The function zeta computes the value
@@ -119,54 +166,185 @@ func computeZetaFromScratch(n uint64, theta float64) (float64, error) {
 	return zeta, nil
 }
 
+// zetaExactTerms is the number of leading terms of zeta that are always
+// summed exactly. Terms beyond it are approximated when there are more than
+// zetaExactTerms of them.
+const zetaExactTerms = 1000
+
+// zetaTerms returns the sum of 1/i^theta for i from a to b. The terms up to
+// zetaExactTerms are summed one by one, and the rest with the Euler-Maclaurin
+// formula, so that computing zeta for billions of values takes microseconds
+// rather than minutes.
+func zetaTerms(a, b uint64, theta float64) float64 {
+	if b-a < zetaExactTerms {
+		return sumZetaTerms(a, b, theta)
+	}
+	var sum float64
+	if a < zetaExactTerms {
+		sum = sumZetaTerms(a, zetaExactTerms-1, theta)
+		a = zetaExactTerms
+	}
+	return sum + approximateZetaTerms(a, b, theta)
+}
+
+// sumZetaTerms returns the sum of 1/i^theta for i from a to b, term by term.
+func sumZetaTerms(a, b uint64, theta float64) float64 {
+	var sum float64
+	for i := a; i <= b; i++ {
+		sum += 1.0 / math.Pow(float64(i), theta)
+	}
+	return sum
+}
+
+// approximateZetaTerms returns the sum of f(i) = 1/i^theta for i from a to b
+// using the Euler-Maclaurin formula:
+//
+//	integral(f, a, b) + (f(a) + f(b))/2 + sum_k B_2k/(2k)! (f^(2k-1)(b) - f^(2k-1)(a))
+//
+// with the corrections up to k = 3. The first omitted correction is at most
+// theta(theta+1)...(theta+6) a^-(theta+7) / 1209600, which for a >=
+// zetaExactTerms and theta <= 10 is below 1e-20, far less than the rounding
+// error of summing the terms exactly.
+func approximateZetaTerms(a, b uint64, theta float64) float64 {
+	fa, fb := float64(a), float64(b)
+	// The integral of x^-theta from a to b, written so that it is accurate
+	// for theta close to or equal to 1.
+	logRatio := math.Log(fb / fa)
+	integral := math.Pow(fa, 1.0-theta) * logRatio * expm1OverX((1.0-theta)*logRatio)
+
+	// derivative returns the nth derivative of x^-theta, for odd n.
+	derivative := func(x float64, n int) float64 {
+		d := -1.0
+		for j := 0; j < n; j++ {
+			d *= theta + float64(j)
+		}
+		return d * math.Pow(x, -theta-float64(n))
+	}
+	corrections := (derivative(fb, 1)-derivative(fa, 1))/12.0 -
+		(derivative(fb, 3)-derivative(fa, 3))/720.0 +
+		(derivative(fb, 5)-derivative(fa, 5))/30240.0
+
+	return integral + (math.Pow(fa, -theta)+math.Pow(fb, -theta))/2.0 + corrections
+}
+
 // Uint64 draws a new value between iMin and iMax, with probabilities
-// according to the Zipf distribution.
-func (z *ZipfGenerator) Uint64() uint64 {
-	z.zipfGenMu.mu.Lock()
-	u := z.zipfGenMu.r.Float64()
-	uz := u * z.zipfGenMu.zetaN
+// according to the Zipf distribution, using r as the source of randomness. r
+// must not be shared with other goroutines.
+func (z *ZipfGenerator) Uint64(r *rand.Rand) uint64 {
+	s := z.loadState()
+	if z.rejectionInversion {
+		result := z.iMin + z.rejectionInversionRank(r, s) - 1
+		if z.verbose {
+			fmt.Printf("Uint64[%d, %d] -> %d\n", z.iMin, s.iMax, result)
+		}
+		return result
+	}
+	u := r.Float64()
+	uz := u * s.zetaN
 	var result uint64
 	if uz < 1.0 {
 		result = z.iMin
 	} else if uz < 1.0+math.Pow(0.5, z.theta) {
 		result = z.iMin + 1
 	} else {
-		spread := float64(z.zipfGenMu.iMax + 1 - z.iMin)
-		result = z.iMin + uint64(spread*math.Pow(z.zipfGenMu.eta*u-z.zipfGenMu.eta+1.0, z.alpha))
+		spread := float64(s.iMax + 1 - z.iMin)
+		result = z.iMin + uint64(spread*math.Pow(s.eta*u-s.eta+1.0, z.alpha))
 	}
 	if z.verbose {
-		fmt.Printf("Uint64[%d, %d] -> %d\n", z.iMin, z.zipfGenMu.iMax, result)
+		fmt.Printf("Uint64[%d, %d] -> %d\n", z.iMin, s.iMax, result)
 	}
-	z.zipfGenMu.mu.Unlock()
 	return result
 }
 
+// rejectionInversionRank draws a rank between 1 and the number of values
+// using rejection-inversion. This follows the implementation of [2] in the
+// Apache Commons RNG RejectionInversionZipfSampler.
+func (z *ZipfGenerator) rejectionInversionRank(r *rand.Rand, s *zipfState) uint64 {
+	n := float64(s.iMax + 1 - z.iMin)
+	for {
+		u := s.hIntegralN + r.Float64()*(z.hIntegralX1-s.hIntegralN)
+		x := z.hIntegralInverse(u)
+		k := math.Floor(x + 0.5)
+		if k < 1 {
+			k = 1
+		} else if k > n {
+			k = n
+		}
+		if k-x <= z.s || u >= z.hIntegral(k+0.5)-z.h(k) {
+			return uint64(k)
+		}
+	}
+}
+
+// h is the (unnormalized) density x^-theta.
+func (z *ZipfGenerator) h(x float64) float64 {
+	return math.Exp(-z.theta * math.Log(x))
+}
+
+// hIntegral is H(x), the integral of h, shifted so that it is well behaved
+// when theta is 1: (x^(1-theta) - 1) / (1 - theta), or log(x) if theta is 1.
+func (z *ZipfGenerator) hIntegral(x float64) float64 {
+	logX := math.Log(x)
+	return expm1OverX((1.0-z.theta)*logX) * logX
+}
+
+// hIntegralInverse is the inverse of hIntegral.
+func (z *ZipfGenerator) hIntegralInverse(x float64) float64 {
+	t := x * (1.0 - z.theta)
+	if t < -1.0 {
+		// Limit t to -1 to avoid NaNs from rounding errors.
+		t = -1.0
+	}
+	return math.Exp(log1pOverX(t) * x)
+}
+
+// log1pOverX returns log(1+x)/x, which is 1 at x = 0.
+func log1pOverX(x float64) float64 {
+	if math.Abs(x) > 1e-8 {
+		return math.Log1p(x) / x
+	}
+	return 1.0 - x*(0.5-x*(1.0/3.0-0.25*x))
+}
+
+// expm1OverX returns (exp(x)-1)/x, which is 1 at x = 0.
+func expm1OverX(x float64) float64 {
+	if math.Abs(x) > 1e-8 {
+		return math.Expm1(x) / x
+	}
+	return 1.0 + x*0.5*(1.0+x*(1.0/3.0)*(1.0+0.25*x))
+}
+
 // IncrementIMax increments, iMax, and recompute the internal values that depend
-// on it. It throws an error if the recomputation failed.
+// on it. It throws an error if the recomputation failed. Concurrent increments
+// retry until their new state is published, so none are lost.
 func (z *ZipfGenerator) IncrementIMax() error {
-	z.zipfGenMu.mu.Lock()
-	zetaN, err := computeZetaIncrementally(
-		z.zipfGenMu.iMax, z.zipfGenMu.iMax+1, z.theta, z.zipfGenMu.zetaN)
-	if err != nil {
-		z.zipfGenMu.mu.Unlock()
-		return errors.Errorf("Could not incrementally compute zeta: %s", err)
+	for {
+		old := z.loadState()
+		var zetaN float64
+		if !z.rejectionInversion {
+			var err error
+			zetaN, err = computeZetaIncrementally(old.iMax, old.iMax+1, z.theta, old.zetaN)
+			if err != nil {
+				return errors.Errorf("Could not incrementally compute zeta: %s", err)
+			}
+		}
+		s := z.newState(old.iMax+1, zetaN)
+		if atomic.CompareAndSwapPointer(&z.state, unsafe.Pointer(old), unsafe.Pointer(s)) {
+			return nil
+		}
 	}
-	eta := (1 - math.Pow(2.0/float64(z.zipfGenMu.iMax+1-z.iMin), 1.0-z.theta)) / (1.0 - z.zeta2/zetaN)
-	z.zipfGenMu.eta = eta
-	z.zipfGenMu.zetaN = zetaN
-	z.zipfGenMu.iMax++
-	z.zipfGenMu.mu.Unlock()
-	return nil
 }
 
 // IMaxHead returns the current value of IMaxHead, and increments it after.
 func (z *ZipfGenerator) IMaxHead() uint64 {
-	z.zipfGenMu.mu.Lock()
-	if z.zipfGenMu.iMaxHead < z.zipfGenMu.iMax {
-		z.zipfGenMu.iMaxHead = z.zipfGenMu.iMax
-	}
-	iMaxHead := z.zipfGenMu.iMaxHead
-	z.zipfGenMu.iMaxHead++
-	z.zipfGenMu.mu.Unlock()
-	return iMaxHead
+	for {
+		head := atomic.LoadUint64(&z.iMaxHead)
+		iMaxHead := head
+		if iMax := z.IMax(); iMaxHead < iMax {
+			iMaxHead = iMax
+		}
+		if atomic.CompareAndSwapUint64(&z.iMaxHead, head, iMaxHead+1) {
+			return iMaxHead
+		}
+	}
 }
@@ -20,7 +20,9 @@ package main
 import (
 	"fmt"
 	"math"
+	"math/rand"
 	"sort"
+	"sync"
 	"testing"
 )
 
@@ -32,6 +34,8 @@ type params struct {
 var gens = []params{
 	{0, 100, 0.99},
 	{0, 100, 1.01},
+	{0, 100, 1.0},
+	{0, 100, 2.0},
 }
 
 func TestCreateZipfGenerator(t *testing.T) {
@@ -68,6 +72,28 @@ func TestZetaFromScratch(t *testing.T) {
 	}
 }
 
+// TestZetaApproximation compares the Euler-Maclaurin approximation used for
+// large n against the exact sum of the terms.
+func TestZetaApproximation(t *testing.T) {
+	for _, theta := range []float64{0.0, 0.5, 0.99, 1.0, 1.01, 2.0, 5.0} {
+		for _, bounds := range [][2]uint64{
+			{1, 1000000},
+			{1, 123457},
+			{999, 5000},
+			{1000, 1001},
+			{25000, 2000000},
+		} {
+			a, b := bounds[0], bounds[1]
+			exact := sumZetaTerms(a, b, theta)
+			computed := zetaTerms(a, b, theta)
+			if math.Abs(computed-exact) > 1e-12*exact {
+				t.Errorf("sum of 1/i^%f for i in [%d, %d]: expected %.15g, got %.15g",
+					theta, a, b, exact, computed)
+			}
+		}
+	}
+}
+
 func TestZetaIncrementally(t *testing.T) {
 	// Theta cannot be 1 by definition, so this is a safe initial value.
 	oldTheta := 1.0
@@ -107,20 +133,19 @@ func runZipfGenerators(t *testing.T, withIncrements bool) {
 
 	const ROLLS = 10000
 	x := make([]int, ROLLS)
+	r := rand.New(rand.NewSource(1))
 
 	for i := 0; i < ROLLS; i++ {
-		x[i] = int(z.Uint64())
-		z.zipfGenMu.mu.Lock()
-		if x[i] < int(z.iMin) || x[i] > int(z.zipfGenMu.iMax) {
-			t.Fatalf("zipf(%d,%d,%f) rolled %d at index %d", z.iMin, z.zipfGenMu.iMax, z.theta, x[i], i)
-			z.zipfGenMu.mu.Unlock()
+		x[i] = int(z.Uint64(r))
+		iMax := z.IMax()
+		if x[i] < int(z.iMin) || x[i] > int(iMax) {
+			t.Fatalf("zipf(%d,%d,%f) rolled %d at index %d", z.iMin, iMax, z.theta, x[i], i)
 			if withIncrements {
 				if err := z.IncrementIMax(); err != nil {
 					t.Fatalf("could not increment iMax: %s", err)
 				}
 			}
 		}
-		z.zipfGenMu.mu.Unlock()
 	}
 
 	if withIncrements {
@@ -156,3 +181,183 @@ func TestZipfGenerator(t *testing.T) {
 	runZipfGenerators(t, false)
 	runZipfGenerators(t, true)
 }
+
+// TestZipfGeneratorRejectionInversion checks that the observed frequency of
+// each value matches the Zipf probability mass function for values of theta
+// that use rejection-inversion, including after incrementing iMax.
+func TestZipfGeneratorRejectionInversion(t *testing.T) {
+	const draws = 200000
+	for _, theta := range []float64{1.0, 1.01, 1.5, 3.0} {
+		for _, increments := range []int{0, 50} {
+			z, err := NewZipfGenerator(1, 50, theta, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < increments; i++ {
+				if err := z.IncrementIMax(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			n := z.IMax()
+
+			counts := make([]int, n+1)
+			r := rand.New(rand.NewSource(1))
+			for i := 0; i < draws; i++ {
+				x := z.Uint64(r)
+				if x < 1 || x > n {
+					t.Fatalf("theta %f: rolled %d outside [1, %d]", theta, x, n)
+				}
+				counts[x]++
+			}
+
+			zetaN, err := computeZetaFromScratch(n, theta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k := uint64(1); k <= n; k++ {
+				expected := math.Pow(float64(k), -theta) / zetaN
+				observed := float64(counts[k]) / draws
+				// Allow 5 standard deviations of the binomial count.
+				if tolerance := 5 * math.Sqrt(expected*(1-expected)/draws); math.Abs(observed-expected) > tolerance {
+					t.Errorf("theta %f, iMax %d: P(%d) = %f, expected %f", theta, n, k, observed, expected)
+				}
+			}
+		}
+	}
+}
+
+// TestZipfGeneratorLargeKeyspace checks that a generator over a billion
+// values, which relies on the approximation of zeta, draws the two most
+// frequent values with the probabilities of the Zipf distribution. The method
+// of [1] is exact for these two values.
+func TestZipfGeneratorLargeKeyspace(t *testing.T) {
+	const iMax = 1000000000
+	const draws = 200000
+	for _, theta := range []float64{0.5, 0.99} {
+		z, err := NewZipfGenerator(1, iMax, theta, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var counts [3]int
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < draws; i++ {
+			if x := z.Uint64(r); x <= 2 {
+				counts[x]++
+			}
+		}
+
+		zetaN, err := computeZetaFromScratch(iMax, theta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k := 1; k <= 2; k++ {
+			expected := math.Pow(float64(k), -theta) / zetaN
+			observed := float64(counts[k]) / draws
+			if tolerance := 5 * math.Sqrt(expected*(1-expected)/draws); math.Abs(observed-expected) > tolerance {
+				t.Errorf("theta %f: P(%d) = %f, expected %f", theta, k, observed, expected)
+			}
+		}
+	}
+}
+
+func TestZipfGeneratorConcurrentIncrements(t *testing.T) {
+	gen := gens[0]
+	z, err := NewZipfGenerator(gen.iMin, gen.iMax, gen.theta, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const goroutines = 8
+	const increments = 1000
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < increments; i++ {
+				if err := z.IncrementIMax(); err != nil {
+					t.Error(err)
+					return
+				}
+				if x := z.Uint64(r); x < z.iMin || x > z.IMax() {
+					t.Errorf("rolled %d outside [%d, %d]", x, z.iMin, z.IMax())
+					return
+				}
+				z.IMaxHead()
+			}
+		}(int64(g))
+	}
+	wg.Wait()
+
+	if expected := gen.iMax + goroutines*increments; z.IMax() != expected {
+		t.Fatalf("expected iMax %d after concurrent increments, got %d", expected, z.IMax())
+	}
+	if head := z.IMaxHead(); head < z.IMax() {
+		t.Fatalf("expected iMaxHead >= %d, got %d", z.IMax(), head)
+	}
+}
+
+// runConcurrently splits b.N calls to f across the given number of
+// goroutines, each with its own RNG. A goroutine stops at the first error
+// returned by f, which fails the benchmark.
+func runConcurrently(b *testing.B, goroutines int, f func(r *rand.Rand) error) {
+	var wg sync.WaitGroup
+	b.ResetTimer()
+	for g := 0; g < goroutines; g++ {
+		n := b.N / goroutines
+		if g < b.N%goroutines {
+			n++
+		}
+		wg.Add(1)
+		go func(seed int64, n int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < n; i++ {
+				if err := f(r); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		}(int64(g), n)
+	}
+	wg.Wait()
+}
+
+var benchGoroutines = []int{1, 2, 4, 8, 16, 32}
+
+func BenchmarkZipfGeneratorUint64(b *testing.B) {
+	for _, goroutines := range benchGoroutines {
+		b.Run(fmt.Sprintf("goroutines=%d", goroutines), func(b *testing.B) {
+			z, err := NewZipfGenerator(1, 10000, 0.99, false)
+			if err != nil {
+				b.Fatal(err)
+			}
+			runConcurrently(b, goroutines, func(r *rand.Rand) error {
+				z.Uint64(r)
+				return nil
+			})
+		})
+	}
+}
+
+// BenchmarkZipfGeneratorMixed draws keys with 5% of calls also inserting a
+// new key, like ycsb workload B.
+func BenchmarkZipfGeneratorMixed(b *testing.B) {
+	for _, goroutines := range benchGoroutines {
+		b.Run(fmt.Sprintf("goroutines=%d", goroutines), func(b *testing.B) {
+			z, err := NewZipfGenerator(1, 10000, 0.99, false)
+			if err != nil {
+				b.Fatal(err)
+			}
+			runConcurrently(b, goroutines, func(r *rand.Rand) error {
+				if r.Intn(20) == 0 {
+					z.IMaxHead()
+					return z.IncrementIMax()
+				}
+				z.Uint64(r)
+				return nil
+			})
+		})
+	}
+}